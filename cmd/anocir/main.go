@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	_ "github.com/nixpig/anocir/internal/nssetup"
@@ -9,6 +10,11 @@ import (
 
 func main() {
 	if err := cli.RootCmd().Execute(); err != nil {
+		var exitErr *cli.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		os.Exit(1)
 	}
 }
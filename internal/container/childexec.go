@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -31,6 +32,9 @@ type ChildExecOpts struct {
 	AppArmorProfile string
 	ProcessLabel    string
 	Cgroup          string
+	// SeccompListenerSock, if set, is the socket the seccomp notify fd is sent
+	// to the runtime on.
+	SeccompListenerSock *os.File
 }
 
 // ChildExec handles the execution of a command in an existing container with
@@ -42,14 +46,32 @@ func ChildExec(opts *ChildExecOpts) error {
 	// Note: namespace joining and chroot to container root is handled by the
 	// C constructor (nssetup) which runs before Go starts.
 
-	if err := platform.ApplyProcessSecurity(&platform.ProcessSecurity{
+	security := &platform.ProcessSecurity{
 		User:            opts.User,
 		Capabilities:    opts.Capabilities,
 		Seccomp:         opts.Seccomp,
 		NoNewPrivs:      opts.NoNewPrivs,
 		AppArmorProfile: opts.AppArmorProfile,
 		ProcessLabel:    opts.ProcessLabel,
-	}); err != nil {
+	}
+
+	if opts.SeccompListenerSock != nil {
+		conn, err := net.FileConn(opts.SeccompListenerSock)
+		if err != nil {
+			return fmt.Errorf("seccomp listener sock file conn: %w", err)
+		}
+
+		// FileConn dups the fd, so close the inherited one to prevent it
+		// leaking into the exec'd process.
+		if err := opts.SeccompListenerSock.Close(); err != nil {
+			slog.Warn("failed to close seccomp listener sock file", "container_id", opts.ContainerID, "err", err)
+		}
+
+		defer conn.Close()
+		security.SeccompListener = sendSeccompListener(conn)
+	}
+
+	if err := platform.ApplyProcessSecurity(security); err != nil {
 		return fmt.Errorf("apply process security: %w", err)
 	}
 
@@ -16,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/nixpig/anocir/internal/container/ipc"
 	"github.com/nixpig/anocir/internal/platform"
@@ -29,6 +30,10 @@ const (
 	// send messages to the container.
 	containerSockFilename = "c.sock"
 
+	// configFilename is the filename of the snapshot of the bundle config
+	// stored in the container directory.
+	configFilename = "config.json"
+
 	// lockFilename is the filename of the lockfile used to synchronise access
 	// to container operations.
 	lockFilename = "c.lock"
@@ -49,13 +54,68 @@ const (
 	// container PID to the reexec'd process.
 	envContainerPID = "_ANOCIR_CONTAINER_PID"
 
+	// envWaitIDMappings is the name of the environment variable used to tell
+	// the reexec'd process to wait for its user namespace mappings to be
+	// written by the parent.
+	envWaitIDMappings = "_ANOCIR_WAIT_ID_MAPPINGS"
+
 	PausedState = specs.ContainerState("paused")
+
+	// DefaultFreezeTimeout is how long to wait for the processes of a
+	// container to be frozen, e.g. when it's paused.
+	DefaultFreezeTimeout = 10 * time.Second
 )
 
 // ErrOperationInProgress is returned when the container is locked by another
 // operation.
 var ErrOperationInProgress = errors.New("operation already in progress")
 
+const (
+	// lockRetryInterval is the initial interval between attempts to acquire
+	// a container lock held by another operation.
+	lockRetryInterval = 10 * time.Millisecond
+
+	// maxLockRetryInterval is the maximum interval between attempts to acquire
+	// a container lock held by another operation.
+	maxLockRetryInterval = 250 * time.Millisecond
+)
+
+// lockTimeout is how long to wait for a container lock held by another
+// operation.
+var lockTimeout time.Duration
+
+// SetLockTimeout sets how long operations wait for a container lock held by
+// another operation before returning ErrOperationInProgress. A zero timeout
+// doesn't wait.
+func SetLockTimeout(timeout time.Duration) {
+	lockTimeout = timeout
+}
+
+// persistedState is the on-disk representation of the container state. It's
+// the OCI state, extended with details only used by the runtime.
+type persistedState struct {
+	*specs.State
+	// PIDStartTime is the start time of the container process. Together with
+	// the PID, it identifies the process so a reused PID isn't mistaken for it.
+	PIDStartTime uint64 `json:"pidStartTime,omitempty"`
+	// RootFS is the absolute path to the container rootfs, resolved against the
+	// bundle when the container was created.
+	RootFS string `json:"rootfs,omitempty"`
+	// Created is the time the container was created.
+	Created time.Time `json:"created,omitzero"`
+	// PIDFile is the file the container process PID is written to, if any.
+	PIDFile string `json:"pidFile,omitempty"`
+	// ConsoleSocket is the socket the console pty was sent to, if any.
+	ConsoleSocket string `json:"consoleSocket,omitempty"`
+	// CgroupDriver is the driver the container cgroup was created with. It's
+	// empty for containers created before it was recorded, which always used
+	// the systemd driver.
+	CgroupDriver platform.CgroupDriver `json:"cgroupDriver,omitempty"`
+	// Monitored is true when a monitor process is the parent of the container
+	// process and records its ExitStatus.
+	Monitored bool `json:"monitored,omitempty"`
+}
+
 // Container represents an OCI container, including its state, specification,
 // and other runtime details.
 type Container struct {
@@ -72,6 +132,17 @@ type Container struct {
 	containerSock string
 	lockFile      *os.File
 	debug         bool
+	monitor       *exec.Cmd
+	monitored     bool
+	pidStartTime  uint64
+	rootfs        string
+	created       time.Time
+	cgroupDriver  platform.CgroupDriver
+	// uidMappings and gidMappings are the user namespace mappings to write
+	// with newuidmap and newgidmap, when rootless and they can't be set
+	// when the container process is forked.
+	uidMappings []syscall.SysProcIDMap
+	gidMappings []syscall.SysProcIDMap
 }
 
 // Opts holds the options for creating a new Container.
@@ -85,6 +156,11 @@ type Opts struct {
 	LogFile       string
 	Debug         bool
 	LogFormat     string
+	CgroupDriver  platform.CgroupDriver
+	// Monitor starts a monitor process to fork the container process and
+	// record its ExitStatus. Otherwise the caller is the parent of the
+	// container process and is responsible for reaping it.
+	Monitor bool
 }
 
 // New constructs a Container based on the provided opts. The container will be
@@ -94,6 +170,10 @@ func New(opts *Opts) (*Container, error) {
 		return nil, fmt.Errorf("spec must have Linux configuration")
 	}
 
+	if err := platform.ValidateSeccompNotify(opts.Spec.Linux.Seccomp); err != nil {
+		return nil, fmt.Errorf("validate seccomp: %w", err)
+	}
+
 	state := &specs.State{
 		Version:     specs.Version,
 		ID:          opts.ID,
@@ -112,6 +192,9 @@ func New(opts *Opts) (*Container, error) {
 		RootDir:       opts.RootDir,
 		LogFile:       opts.LogFile,
 		containerSock: containerSockPath(opts.Bundle),
+		created:       time.Now().UTC(),
+		cgroupDriver:  opts.CgroupDriver,
+		monitored:     opts.Monitor,
 	}, nil
 }
 
@@ -133,7 +216,16 @@ func (c *Container) save() error {
 		}
 	}
 
-	state, err := json.Marshal(c.State)
+	state, err := json.Marshal(&persistedState{
+		State:         c.State,
+		PIDStartTime:  c.pidStartTime,
+		RootFS:        c.rootfs,
+		Created:       c.created,
+		PIDFile:       c.pidFile,
+		ConsoleSocket: c.ConsoleSocket,
+		CgroupDriver:  c.cgroupDriver,
+		Monitored:     c.monitored,
+	})
 	if err != nil {
 		return fmt.Errorf("serialise container state: %w", err)
 	}
@@ -177,22 +269,57 @@ func (c *Container) Save() error {
 	return c.save()
 }
 
-// Lock acquires an exclusive lock on the container.
+// Lock acquires an exclusive lock on the container, for operations that
+// modify it.
 func (c *Container) Lock() error {
+	return c.lock(unix.LOCK_EX, lockTimeout)
+}
+
+// RLock acquires a shared lock on the container, for operations that only
+// read it. Any number of shared locks can be held at once, but not while an
+// exclusive lock is held.
+func (c *Container) RLock() error {
+	return c.lock(unix.LOCK_SH, lockTimeout)
+}
+
+// lock acquires a lock of the given type, LOCK_EX or LOCK_SH, on the
+// container. If it's held by another operation, it retries until the timeout
+// elapses, then returns ErrOperationInProgress.
+func (c *Container) lock(how int, timeout time.Duration) error {
 	lockPath := filepath.Join(c.containerDir(), lockFilename)
 	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o600)
 	if err != nil {
 		return fmt.Errorf("open lock file: %w", err)
 	}
 
-	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
-		f.Close()
+	deadline := time.Now().Add(timeout)
+	interval := lockRetryInterval
+
+	for {
+		err := unix.Flock(int(f.Fd()), how|unix.LOCK_NB)
+		if err == nil {
+			break
+		}
 
-		if err == unix.EWOULDBLOCK {
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+
+		if !errors.Is(err, unix.EWOULDBLOCK) {
+			f.Close()
+			return fmt.Errorf("acquire file lock: %w", err)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			f.Close()
 			return ErrOperationInProgress
 		}
 
-		return fmt.Errorf("acquire file lock: %w", err)
+		slog.Debug("container locked, retry", "container_id", c.State.ID, "interval", interval)
+
+		time.Sleep(min(interval, remaining))
+		interval = min(interval*2, maxLockRetryInterval)
 	}
 
 	c.lockFile = f
@@ -244,7 +371,7 @@ func (c *Container) Delete(force bool) error {
 		)
 	}
 
-	if err := platform.DeleteCgroup(c.spec.Linux.CgroupsPath, c.State.ID); err != nil {
+	if err := platform.DeleteCgroup(c.cgroup()); err != nil {
 		slog.Warn("failed to delete cgroup", "container_id", c.State.ID, "path", c.spec.Linux.CgroupsPath, "err", err)
 		fmt.Fprintf(os.Stderr, "Warning: failed to delete cgroup (path: %s): %s\n", c.spec.Linux.CgroupsPath, err.Error())
 	}
@@ -277,7 +404,7 @@ func (c *Container) Delete(force bool) error {
 // process is no longer running, it updates the container state to be 'stopped'
 // before returning.
 func (c *Container) GetState() (*specs.State, error) {
-	if err := c.Lock(); err != nil {
+	if err := c.RLock(); err != nil {
 		return nil, fmt.Errorf("acquire container lock: %w", err)
 	}
 	defer c.Unlock()
@@ -290,6 +417,8 @@ func (c *Container) GetState() (*specs.State, error) {
 	if err != nil {
 		slog.Debug("failed to check if process is dead, assume it's alive", "container_id", c.State.ID, "pid", c.State.Pid, "err", err)
 	} else if dead {
+		// Only a shared lock is held, but concurrent readers write the same
+		// state and writes are atomic.
 		c.State.Status = specs.StateStopped
 		if err := c.save(); err != nil {
 			return nil, fmt.Errorf("save stopped state: %w", err)
@@ -299,10 +428,26 @@ func (c *Container) GetState() (*specs.State, error) {
 	return c.State, nil
 }
 
+// GetRootFS returns the absolute path to the container rootfs.
+func (c *Container) GetRootFS() string {
+	return c.rootFS()
+}
+
+// GetCreated returns the time the container was created. It's zero for
+// containers created before the time was recorded.
+func (c *Container) GetCreated() time.Time {
+	return c.created
+}
+
 func (c *Container) GetSpec() *specs.Spec {
 	return c.spec
 }
 
+// GetCgroup returns the cgroup of the container.
+func (c *Container) GetCgroup() platform.Cgroup {
+	return c.cgroup()
+}
+
 // Start begins the execution of the container by sending the start message to
 // the runtime process.
 func (c *Container) Start() error {
@@ -356,7 +501,12 @@ func (c *Container) Start() error {
 	// container has fully initialized (seccomp loaded, capabilities set, etc.)
 	// before we report it as running.
 	slog.Debug("waiting for exec ready message", "container_id", c.State.ID)
-	execReadyMsg, err := ipc.ReceiveMessage(conn)
+	execReadyMsg, err := receiveMessage(
+		conn,
+		c.spec.Linux.Seccomp,
+		c.State,
+		c.State.Pid,
+	)
 	if err != nil {
 		return fmt.Errorf("receive exec ready message: %w", err)
 	}
@@ -406,12 +556,18 @@ func (c *Container) Kill(sig string, killAll bool) error {
 		return fmt.Errorf("parse signal: %w", err)
 	}
 
+	// Without a cgroup the container's processes can't be found, so only the
+	// container process is signalled.
+	if killAll && c.cgroup().Driver == platform.NoCgroupDriver {
+		slog.Debug("container has no cgroup, kill container process only", "container_id", c.State.ID)
+		killAll = false
+	}
+
 	if killAll {
-		pids, err := platform.GetCgroupProcesses(c.spec.Linux.CgroupsPath, c.State.ID)
+		pids, err := platform.GetCgroupProcesses(c.cgroup())
 		if err != nil {
 			// cgroup may already be dead
 			slog.Debug("get cgroup processes for kill all", "err", err)
-			return nil
 		}
 
 		for _, pid := range pids {
@@ -446,8 +602,8 @@ func (c *Container) Kill(sig string, killAll bool) error {
 			"signal", unixSig,
 		)
 
-		if err := platform.SendSignal(c.State.Pid, unixSig); err != nil {
-			if errors.Is(err, unix.ESRCH) {
+		if err := c.Signal(unixSig); err != nil {
+			if errors.Is(err, platform.ErrProcessNotFound) {
 				return fmt.Errorf("container not running: %w", err)
 			}
 
@@ -458,23 +614,106 @@ func (c *Container) Kill(sig string, killAll bool) error {
 		}
 	}
 
+	// SIGKILL is delivered to frozen processes, so a paused container is only
+	// thawed once it's been killed, to not leave its cgroup frozen. Other
+	// signals stay pending until it's resumed.
+	if unixSig == unix.SIGKILL && c.State.Status == PausedState {
+		if err := c.thaw(); err != nil {
+			slog.Warn("failed to thaw killed container", "container_id", c.State.ID, "err", err)
+		}
+	}
+
 	return nil
 }
 
-// Init prepares the container for execution. It executes hooks, sets up the terminal
-// if necessary, and re-execs the runtime binary to containerise the process.
+// Signal sends sig to the container process, verifying it's still the same
+// process so that a reused PID is never signalled. Unlike Kill, it doesn't
+// acquire the container lock.
+func (c *Container) Signal(sig unix.Signal) error {
+	return platform.SignalProcess(c.State.Pid, c.pidStartTime, sig)
+}
+
+// Init prepares the container for execution and waits for it to be created.
+// The container process is forked by the monitor process, if the container
+// is monitored, which remains its parent for the lifetime of the container.
+// Otherwise it's forked directly, and the caller of the runtime is its parent.
 func (c *Container) Init() error {
 	if err := c.Lock(); err != nil {
 		return fmt.Errorf("acquire container lock: %w", err)
 	}
 	defer c.Unlock()
 
+	// If Init fails, everything acquired for the container is released,
+	// including the container directory created by the caller, so the ID can
+	// be reused.
+	rb := newRollback(c.State.ID)
+	defer rb.run()
+
+	rb.add("remove container directory", func() error {
+		return os.RemoveAll(c.containerDir())
+	})
+	rb.add("remove container socket directory", func() error {
+		return os.RemoveAll(filepath.Dir(c.containerSock))
+	})
+	rb.add("clean up container process", c.cleanupOrphanedProcess)
+
+	// Snapshot the config so later operations aren't affected by changes to,
+	// or removal of, the bundle.
+	c.rootfs = c.rootFS()
+	if err := c.saveConfig(); err != nil {
+		return fmt.Errorf("save container config: %w", err)
+	}
+
 	if err := c.save(); err != nil {
 		return fmt.Errorf("save initial container state: %w", err)
 	}
 
 	slog.Debug("init container", "container_id", c.State.ID, "bundle", c.State.Bundle)
 
+	if c.monitored {
+		if err := c.startMonitor(rb); err != nil {
+			return err
+		}
+	} else if err := c.forkReexec(); err != nil {
+		return err
+	}
+
+	if err := c.reloadState(); err != nil {
+		return fmt.Errorf("reload container state: %w", err)
+	}
+
+	rb.commit()
+
+	return nil
+}
+
+// cleanupOrphanedProcess kills the container process and deletes its cgroup,
+// if the monitor process recorded one. It handles the monitor process failing
+// without rolling back itself, e.g. because it was killed.
+func (c *Container) cleanupOrphanedProcess() error {
+	if err := c.reloadState(); err != nil || c.State.Pid == 0 {
+		return nil
+	}
+
+	if err := c.Signal(unix.SIGKILL); err != nil &&
+		!errors.Is(err, platform.ErrProcessNotFound) {
+		return fmt.Errorf("kill container process: %w", err)
+	}
+
+	if err := platform.DeleteCgroup(c.cgroup()); err != nil {
+		return fmt.Errorf("delete cgroup: %w", err)
+	}
+
+	return nil
+}
+
+// forkReexec executes hooks, sets up the terminal if necessary, and re-execs
+// the runtime binary to containerise the process. It's called by Init, or by
+// the monitor process while the runtime holds the container lock.
+func (c *Container) forkReexec() error {
+	rb := newRollback(c.State.ID)
+	defer rb.run()
+
 	args := []string{
 		"reexec",
 		"--root", c.RootDir,
@@ -521,6 +760,9 @@ func (c *Container) Init() error {
 	if err := os.MkdirAll(filepath.Dir(c.containerSock), 0o755); err != nil {
 		return fmt.Errorf("create container socket directory: %w", err)
 	}
+	rb.add("remove container socket directory", func() error {
+		return os.RemoveAll(filepath.Dir(c.containerSock))
+	})
 
 	containerSock := ipc.NewSocket(c.containerSock)
 	listener, err := containerSock.Listen()
@@ -585,21 +827,50 @@ func (c *Container) Init() error {
 
 	c.State.Pid = cmd.Process.Pid
 
+	// The cgroup is deleted after the container process is killed, since it
+	// can't be deleted while it contains processes. It's added before the
+	// cgroup is created so a partially created cgroup is also deleted.
+	rb.add("delete cgroup", func() error {
+		return platform.DeleteCgroup(c.cgroup())
+	})
+	rb.add("kill container process", func() error {
+		if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+
+		// Reap the container process. Its exit status isn't relevant.
+		_ = cmd.Wait()
+
+		return nil
+	})
+
+	// The process is a child that hasn't been reaped, so it can't have been
+	// replaced by another process with the same PID.
+	c.pidStartTime, err = platform.ProcessStartTime(c.State.Pid)
+	if err != nil {
+		return fmt.Errorf("get container process start time: %w", err)
+	}
+
 	slog.Debug(
 		"create cgroup",
 		"container_id", c.State.ID,
 		"pid", c.State.Pid,
 		"cgroups_path", c.spec.Linux.CgroupsPath,
+		"cgroup_driver", c.cgroup().Driver,
 	)
-	if err := platform.CreateCgroup(
-		c.spec.Linux.CgroupsPath,
-		c.State.ID,
+	unified, err := platform.CreateCgroup(
+		c.cgroup(),
 		c.State.Pid,
-		c.spec.Linux.Resources,
-	); err != nil {
+		c.cgroupResources(c.spec.Linux.Resources),
+	)
+	if err != nil {
 		return fmt.Errorf("create cgroups: %w", err)
 	}
 
+	if len(unified) > 0 {
+		slog.Info("applied unified cgroup settings", "container_id", c.State.ID, "keys", unified)
+	}
+
 	conn, err := net.FileConn(initSockParent)
 	if err != nil {
 		return fmt.Errorf("accept on init sock parent: %w", err)
@@ -610,6 +881,20 @@ func (c *Container) Init() error {
 		}
 	}()
 
+	if c.uidMappings != nil {
+		if err := platform.WriteIDMappings(
+			c.State.Pid,
+			c.uidMappings,
+			c.gidMappings,
+		); err != nil {
+			return fmt.Errorf("write user namespace mappings: %w", err)
+		}
+
+		if err := ipc.SendMessage(conn, ipc.MsgIDMapped); err != nil {
+			return fmt.Errorf("send id mapped message: %w", err)
+		}
+	}
+
 	prePivotMsg, err := ipc.ReceiveMessage(conn)
 	if err != nil {
 		return fmt.Errorf("read prepivot message: %w", err)
@@ -637,22 +922,18 @@ func (c *Container) Init() error {
 
 	slog.Debug("received ready message", "container_id", c.State.ID, "message", readyMsg)
 
-	switch readyMsg {
-	case ipc.MsgInvalidExecutable:
-		return errors.New("invalid executable")
-	case ipc.MsgReady:
-		// Let's go!
-	default:
+	if readyMsg != ipc.MsgReady {
 		return fmt.Errorf(
-			"expected MsgReady ('%b') or MsgInvalidExecutable ('%b') but got '%b'",
+			"expected MsgReady ('%b') but got '%b'",
 			ipc.MsgReady,
-			ipc.MsgInvalidExecutable,
 			readyMsg,
 		)
 	}
 
-	if err := cmd.Process.Release(); err != nil {
-		return fmt.Errorf("release container process: %w", err)
+	if c.pidFile != "" {
+		rb.add("remove pid file", func() error {
+			return os.RemoveAll(c.pidFile)
+		})
 	}
 
 	c.State.Status = specs.StateCreated
@@ -660,6 +941,12 @@ func (c *Container) Init() error {
 		return fmt.Errorf("save created state: %w", err)
 	}
 
+	rb.commit()
+
+	if err := cmd.Process.Release(); err != nil {
+		return fmt.Errorf("release container process: %w", err)
+	}
+
 	return nil
 }
 
@@ -688,6 +975,12 @@ func (c *Container) Reexec() error {
 		return fmt.Errorf("init sock file conn: %w", err)
 	}
 
+	if os.Getenv(envWaitIDMappings) != "" {
+		if err := waitIDMappings(initConn); err != nil {
+			return fmt.Errorf("wait for user namespace mappings: %w", err)
+		}
+	}
+
 	containerSockFD := os.Getenv(envContainerSockFD)
 	if containerSockFD == "" {
 		return errors.New("missing container sock fd")
@@ -721,36 +1014,32 @@ func (c *Container) Reexec() error {
 	}
 
 	if err := c.setupPrePivot(); err != nil {
-		return fmt.Errorf("setup pre-pivot: %w", err)
+		return c.reexecError(initConn, "setup pre-pivot", err)
 	}
 
 	if err := c.connectConsole(); err != nil {
-		return fmt.Errorf("connect console: %w", err)
+		return c.reexecError(initConn, "connect console", err)
 	}
 
 	if err := c.mountConsole(); err != nil {
-		return fmt.Errorf("mount console: %w", err)
+		return c.reexecError(initConn, "mount console", err)
 	}
 
 	if c.hasMountNamespace() {
 		if err := c.pivotRoot(); err != nil {
-			return fmt.Errorf("pivot_root: %w", err)
+			return c.reexecError(initConn, "pivot_root", err)
 		}
 
 		if c.spec.Process != nil {
 			if _, err := exec.LookPath(c.spec.Process.Args[0]); err != nil {
-				slog.Debug("send invalid executable message", "container_id", c.State.ID)
-				if err := ipc.SendMessage(initConn, ipc.MsgInvalidExecutable); err != nil {
-					return fmt.Errorf("failed to send invalid exec message: %w", err)
-				}
-				return fmt.Errorf("find path of user process exe: %w", err)
+				return c.reexecError(initConn, "find path of user process exe", err)
 			}
 		}
 	}
 
 	slog.Debug("execute createcontainer hooks", "container_id", c.State.ID)
 	if err := c.execHooks(LifecycleCreateContainer); err != nil {
-		return fmt.Errorf("exec createcontainer hooks: %w", err)
+		return c.reexecError(initConn, "exec createcontainer hooks", err)
 	}
 
 	slog.Debug("send ready message", "container_id", c.State.ID)
@@ -784,14 +1073,14 @@ func (c *Container) Reexec() error {
 	}
 
 	if c.spec.Process != nil {
-		if err := c.setupPostPivot(); err != nil {
-			return fmt.Errorf("setup post-pivot: %w", err)
+		if err := c.setupPostPivot(containerConn); err != nil {
+			return c.reexecError(containerConn, "setup post-pivot", err)
 		}
 	}
 
 	slog.Debug("execute startcontainer hooks", "container_id", c.State.ID)
 	if err := c.execHooks(LifecycleStartContainer); err != nil {
-		return fmt.Errorf("exec startcontainer hooks: %w", err)
+		return c.reexecError(containerConn, "exec startcontainer hooks", err)
 	}
 
 	if c.spec.Process == nil {
@@ -814,8 +1103,22 @@ func (c *Container) Reexec() error {
 	panic("unreachable")
 }
 
-// Pause pauses a running container by freezing the cgroup.
-func (c *Container) Pause() error {
+// reexecError reports the given err in the given phase of Reexec to the
+// runtime over conn, so it can be returned to the caller, and returns it.
+func (c *Container) reexecError(conn net.Conn, phase string, err error) error {
+	slog.Debug("send error message", "container_id", c.State.ID, "phase", phase, "err", err)
+
+	if sendErr := ipc.SendError(conn, phase, err); sendErr != nil {
+		slog.Warn("failed to send error message", "container_id", c.State.ID, "err", sendErr)
+	}
+
+	return fmt.Errorf("%s: %w", phase, err)
+}
+
+// Pause pauses a running container by freezing the cgroup. If the processes
+// of the container aren't all frozen within timeout, the cgroup is thawed and
+// the container left running.
+func (c *Container) Pause(timeout time.Duration) error {
 	if err := c.Lock(); err != nil {
 		return fmt.Errorf("acquire container lock: %w", err)
 	}
@@ -829,7 +1132,7 @@ func (c *Container) Pause() error {
 		return fmt.Errorf("container cannot be paused in current state (%s)", c.State.Status)
 	}
 
-	if err := platform.FreezeCgroup(c.spec.Linux.CgroupsPath, c.State.ID); err != nil {
+	if err := platform.FreezeCgroup(c.cgroup(), timeout); err != nil {
 		return fmt.Errorf("freeze cgroup: %w", err)
 	}
 
@@ -859,7 +1162,12 @@ func (c *Container) Resume() error {
 		return fmt.Errorf("container cannot be resumed in current state (%s)", c.State.Status)
 	}
 
-	if err := platform.ThawCgroup(c.spec.Linux.CgroupsPath, c.State.ID); err != nil {
+	return c.thaw()
+}
+
+// thaw thaws the cgroup of a paused container and records it's running.
+func (c *Container) thaw() error {
+	if err := platform.ThawCgroup(c.cgroup()); err != nil {
 		return fmt.Errorf("thaw cgroup: %w", err)
 	}
 
@@ -888,14 +1196,23 @@ func (c *Container) configureNamespaces(cmd *exec.Cmd) error {
 				c.spec.Linux.GIDMappings,
 			)
 
-			cmd.SysProcAttr.UidMappings = uidMappings
-			cmd.SysProcAttr.GidMappings = gidMappings
-			cmd.SysProcAttr.GidMappingsEnableSetgroups = false
-
-			// Explicitly set child to UID/GID 0 so it has necessary permissions to
-			// pick up the mapped credentials and capabilities from /proc/<pid>/uid_map
-			// and /proc/<pid>/gid_map.
-			cmd.SysProcAttr.Credential = &syscall.Credential{Uid: 0, Gid: 0}
+			// An unprivileged process can only map its own UID and GID, so
+			// other mappings are written by newuidmap and newgidmap once the
+			// process has been forked.
+			if platform.NeedsIDMapHelpers(uidMappings, gidMappings) {
+				c.uidMappings = uidMappings
+				c.gidMappings = gidMappings
+				cmd.Env = append(cmd.Env, envWaitIDMappings+"=1")
+			} else {
+				cmd.SysProcAttr.UidMappings = uidMappings
+				cmd.SysProcAttr.GidMappings = gidMappings
+				cmd.SysProcAttr.GidMappingsEnableSetgroups = false
+
+				// Explicitly set child to UID/GID 0 so it has necessary permissions to
+				// pick up the mapped credentials and capabilities from /proc/<pid>/uid_map
+				// and /proc/<pid>/gid_map.
+				cmd.SysProcAttr.Credential = &syscall.Credential{Uid: 0, Gid: 0}
+			}
 		}
 
 		if ns.Type == specs.TimeNamespace && c.spec.Linux.TimeOffsets != nil {
@@ -942,6 +1259,33 @@ func (c *Container) configureNamespaces(cmd *exec.Cmd) error {
 	return nil
 }
 
+// waitIDMappings waits for the parent to write the user namespace mappings of
+// the current process, then switches to root in the user namespace.
+func waitIDMappings(conn net.Conn) error {
+	msg, err := ipc.ReceiveMessage(conn)
+	if err != nil {
+		return fmt.Errorf("read id mapped message: %w", err)
+	}
+
+	if msg != ipc.MsgIDMapped {
+		return fmt.Errorf(
+			"expected MsgIDMapped ('%b') but got '%b'",
+			ipc.MsgIDMapped,
+			msg,
+		)
+	}
+
+	if err := syscall.Setresgid(0, 0, 0); err != nil {
+		return fmt.Errorf("set gid: %w", err)
+	}
+
+	if err := syscall.Setresuid(0, 0, 0); err != nil {
+		return fmt.Errorf("set uid: %w", err)
+	}
+
+	return nil
+}
+
 func (c *Container) useTerminal() bool {
 	return c.spec.Process != nil &&
 		c.spec.Process.Terminal &&
@@ -1038,7 +1382,8 @@ func (c *Container) canBeDeleted() bool {
 
 func (c *Container) canBeKilled() bool {
 	return c.State.Status == specs.StateRunning ||
-		c.State.Status == specs.StateCreated
+		c.State.Status == specs.StateCreated ||
+		c.State.Status == PausedState
 }
 
 func (c *Container) canBePaused() bool {
@@ -1051,6 +1396,10 @@ func (c *Container) canBeResumed() bool {
 
 // rootFS returns the path to the Container root filesystem.
 func (c *Container) rootFS() string {
+	if c.rootfs != "" {
+		return c.rootfs
+	}
+
 	if strings.HasPrefix(c.spec.Root.Path, "/") {
 		return c.spec.Root.Path
 	}
@@ -1058,10 +1407,47 @@ func (c *Container) rootFS() string {
 	return filepath.Join(c.State.Bundle, c.spec.Root.Path)
 }
 
+// cgroup returns the platform.Cgroup that identifies the container cgroup.
+func (c *Container) cgroup() platform.Cgroup {
+	driver := c.cgroupDriver
+	if driver == "" {
+		// Containers created before the driver was recorded always used
+		// systemd.
+		driver = platform.SystemdDriver
+	}
+
+	return platform.Cgroup{
+		Driver:      driver,
+		CgroupsPath: c.spec.Linux.CgroupsPath,
+		ContainerID: c.State.ID,
+	}
+}
+
 func (c *Container) stateFilepath() string {
 	return filepath.Join(c.RootDir, c.State.ID, "state.json")
 }
 
+func (c *Container) configFilepath() string {
+	return filepath.Join(c.containerDir(), configFilename)
+}
+
+func (c *Container) saveConfig() error {
+	config, err := json.Marshal(c.spec)
+	if err != nil {
+		return fmt.Errorf("serialise container config: %w", err)
+	}
+
+	if err := platform.AtomicWriteFile(
+		c.configFilepath(),
+		config,
+		0o644,
+	); err != nil {
+		return fmt.Errorf("write container config: %w", err)
+	}
+
+	return nil
+}
+
 func (c *Container) containerDir() string {
 	return filepath.Join(c.RootDir, c.State.ID)
 }
@@ -1074,10 +1460,19 @@ func (c *Container) reloadState() error {
 		return fmt.Errorf("read state file: %w", err)
 	}
 
-	if err := json.Unmarshal(s, c.State); err != nil {
+	state := &persistedState{State: c.State}
+	if err := json.Unmarshal(s, state); err != nil {
 		return fmt.Errorf("unmarshal state: %w", err)
 	}
 
+	c.pidStartTime = state.PIDStartTime
+	c.rootfs = state.RootFS
+	c.created = state.Created
+	c.pidFile = state.PIDFile
+	c.ConsoleSocket = state.ConsoleSocket
+	c.cgroupDriver = state.CgroupDriver
+	c.monitored = state.Monitored
+
 	return nil
 }
 
@@ -1096,9 +1491,8 @@ func (c *Container) isProcessDead() (bool, error) {
 		return false, nil
 	}
 
-	err := unix.Kill(c.State.Pid, 0)
-	if err == unix.ESRCH {
-		return true, nil
-	}
-	return false, err
+	// A process with the PID but a different start time means the container
+	// process exited and its PID was reused.
+	alive, err := platform.IsProcessAlive(c.State.Pid, c.pidStartTime)
+	return !alive, err
 }
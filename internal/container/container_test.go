@@ -5,10 +5,13 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/nixpig/anocir/internal/platform"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 )
 
 func TestContainerLifecycle(t *testing.T) {
@@ -38,6 +41,8 @@ func TestContainerLifecycle(t *testing.T) {
 		spec:          c.GetSpec(),
 		RootDir:       c.RootDir,
 		containerSock: containerSockPath(c.State.Bundle),
+		created:       c.GetCreated(),
+		pidFile:       c.pidFile,
 	}, loaded)
 }
 
@@ -98,7 +103,7 @@ func TestStateChange(t *testing.T) {
 		"from state created":  {specs.StateCreated, true, true, false, false, false},
 		"from state running":  {specs.StateRunning, false, true, false, true, false},
 		"from state stopped":  {specs.StateStopped, false, false, true, false, false},
-		"from state paused":   {PausedState, false, false, false, false, true},
+		"from state paused":   {PausedState, false, true, false, false, true},
 	}
 
 	for scenario, data := range scenarios {
@@ -201,3 +206,184 @@ func newTestContainer(t *testing.T, spec *specs.Spec) *Container {
 
 	return c
 }
+
+func TestProcessIdentity(t *testing.T) {
+	t.Parallel()
+
+	c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+
+	startTime, err := platform.ProcessStartTime(os.Getpid())
+	require.NoError(t, err)
+
+	c.State.Pid = os.Getpid()
+	c.State.Status = specs.StateRunning
+	c.pidStartTime = startTime
+	require.NoError(t, c.Save())
+
+	loaded, err := Load(c.State.ID, c.RootDir)
+	require.NoError(t, err)
+	assert.Equal(t, startTime, loaded.pidStartTime)
+
+	state, err := loaded.GetState()
+	require.NoError(t, err)
+	assert.Equal(t, specs.StateRunning, state.Status)
+
+	// Same PID with a different start time is a reused PID.
+	c.pidStartTime = startTime + 1
+	require.NoError(t, c.Save())
+
+	state, err = c.GetState()
+	require.NoError(t, err)
+	assert.Equal(t, specs.StateStopped, state.Status)
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("test load from snapshot", func(t *testing.T) {
+		t.Parallel()
+
+		c := newTestContainer(t, &specs.Spec{
+			Hostname: "snapshot",
+			Root:     &specs.Root{Path: "rootfs"},
+			Linux:    &specs.Linux{},
+		})
+
+		c.rootfs = c.rootFS()
+		require.NoError(t, c.saveConfig())
+		require.NoError(t, c.Save())
+		require.NoError(t, os.RemoveAll(c.State.Bundle))
+
+		loaded, err := Load(c.State.ID, c.RootDir)
+		require.NoError(t, err)
+		assert.Equal(t, "snapshot", loaded.GetSpec().Hostname)
+		assert.Equal(t, filepath.Join(c.State.Bundle, "rootfs"), loaded.rootFS())
+	})
+
+	t.Run("test load from bundle without snapshot", func(t *testing.T) {
+		t.Parallel()
+
+		c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+
+		_, err := Load(c.State.ID, c.RootDir)
+		require.NoError(t, err)
+		assert.FileExists(t, c.configFilepath())
+	})
+
+	t.Run("test load without snapshot or bundle", func(t *testing.T) {
+		t.Parallel()
+
+		c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+		require.NoError(t, os.RemoveAll(c.State.Bundle))
+
+		_, err := Load(c.State.ID, c.RootDir)
+		assert.ErrorContains(t, err, "has no config snapshot")
+	})
+}
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		held    int
+		how     int
+		timeout time.Duration
+		release bool
+		wantErr error
+	}{
+		"test shared lock while shared lock held": {
+			held: unix.LOCK_SH,
+			how:  unix.LOCK_SH,
+		},
+		"test exclusive lock while shared lock held": {
+			held:    unix.LOCK_SH,
+			how:     unix.LOCK_EX,
+			wantErr: ErrOperationInProgress,
+		},
+		"test shared lock while exclusive lock held": {
+			held:    unix.LOCK_EX,
+			how:     unix.LOCK_SH,
+			wantErr: ErrOperationInProgress,
+		},
+		"test exclusive lock times out": {
+			held:    unix.LOCK_EX,
+			how:     unix.LOCK_EX,
+			timeout: 50 * time.Millisecond,
+			wantErr: ErrOperationInProgress,
+		},
+		"test exclusive lock waits for release": {
+			held:    unix.LOCK_EX,
+			how:     unix.LOCK_EX,
+			timeout: 5 * time.Second,
+			release: true,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+
+			other, err := Load(c.State.ID, c.RootDir)
+			require.NoError(t, err)
+
+			require.NoError(t, other.lock(data.held, 0))
+
+			if data.release {
+				time.AfterFunc(50*time.Millisecond, func() { other.Unlock() })
+			} else {
+				defer other.Unlock()
+			}
+
+			err = c.lock(data.how, data.timeout)
+			if data.wantErr != nil {
+				assert.ErrorIs(t, err, data.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NoError(t, c.Unlock())
+		})
+	}
+}
+
+func TestCgroupDriver(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		driver platform.CgroupDriver
+		want   platform.CgroupDriver
+	}{
+		"test cgroupfs driver": {
+			driver: platform.CgroupfsDriver,
+			want:   platform.CgroupfsDriver,
+		},
+		"test systemd driver": {
+			driver: platform.SystemdDriver,
+			want:   platform.SystemdDriver,
+		},
+		"test unrecorded driver defaults to systemd": {
+			driver: "",
+			want:   platform.SystemdDriver,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+			c.cgroupDriver = data.driver
+			require.NoError(t, c.Save())
+
+			loaded, err := Load(c.State.ID, c.RootDir)
+			require.NoError(t, err)
+
+			assert.Equal(t, platform.Cgroup{
+				Driver:      data.want,
+				ContainerID: c.State.ID,
+			}, loaded.GetCgroup())
+		})
+	}
+}
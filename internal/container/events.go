@@ -0,0 +1,121 @@
+package container
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+const (
+	// EventStats is the type of an Event with the resource usage statistics of
+	// the container.
+	EventStats = "stats"
+
+	// EventOOM is the type of an Event emitted when the container cgroup hits
+	// its memory limit and the OOM killer is invoked.
+	EventOOM = "oom"
+
+	// EventOOMKill is the type of an Event emitted when a process in the
+	// container is killed by the OOM killer.
+	EventOOMKill = "oom_kill"
+)
+
+// Event is an event of a container, in the format of runc events.
+type Event struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Data any    `json:"data,omitempty"`
+}
+
+// Stats returns an EventStats Event with the resource usage of the container.
+func (c *Container) Stats() (*Event, error) {
+	stats, err := platform.GetCgroupStats(c.cgroup())
+	if err != nil {
+		return nil, fmt.Errorf("get cgroup stats: %w", err)
+	}
+
+	return &Event{Type: EventStats, ID: c.State.ID, Data: stats}, nil
+}
+
+// MemoryEvents returns the OOM and OOM kill counts of the container cgroup.
+func (c *Container) MemoryEvents() (*platform.MemoryEvents, error) {
+	events, err := platform.GetCgroupMemoryEvents(c.cgroup())
+	if err != nil {
+		return nil, fmt.Errorf("get cgroup memory events: %w", err)
+	}
+
+	return &events, nil
+}
+
+// Events calls emit with an EventStats Event every interval, and with EventOOM
+// and EventOOMKill Events as they happen, until the container stops or emit
+// returns an error.
+func (c *Container) Events(interval time.Duration, emit func(*Event) error) error {
+	memoryEvents, memoryErrs, err := platform.WatchCgroupMemoryEvents(c.cgroup())
+	if err != nil {
+		return fmt.Errorf("watch memory events: %w", err)
+	}
+
+	// Only report memory events that happen from now on.
+	last, err := platform.GetCgroupMemoryEvents(c.cgroup())
+	if err != nil {
+		return fmt.Errorf("get memory events: %w", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e, ok := <-memoryEvents:
+			if !ok {
+				// The cgroup is empty or deleted, i.e. the container stopped.
+				return <-memoryErrs
+			}
+
+			for _, event := range memoryEventsSince(c.State.ID, last, e) {
+				if err := emit(event); err != nil {
+					return err
+				}
+			}
+
+			last = e
+
+		case <-ticker.C:
+			if err := c.reloadState(); err != nil {
+				return fmt.Errorf("reload container state: %w", err)
+			}
+
+			if c.State.Status == specs.StateStopped {
+				return nil
+			}
+
+			event, err := c.Stats()
+			if err != nil {
+				return err
+			}
+
+			if err := emit(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// memoryEventsSince returns the Events for the increases in the memory event
+// counts from last to current.
+func memoryEventsSince(id string, last, current platform.MemoryEvents) []*Event {
+	var events []*Event
+
+	if current.OOM > last.OOM {
+		events = append(events, &Event{Type: EventOOM, ID: id})
+	}
+
+	if current.OOMKill > last.OOMKill {
+		events = append(events, &Event{Type: EventOOMKill, ID: id})
+	}
+
+	return events
+}
@@ -0,0 +1,49 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryEventsSince(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		last    platform.MemoryEvents
+		current platform.MemoryEvents
+		events  []*Event
+	}{
+		"test no change": {
+			last:    platform.MemoryEvents{OOM: 1, OOMKill: 1},
+			current: platform.MemoryEvents{OOM: 1, OOMKill: 1},
+			events:  nil,
+		},
+		"test oom": {
+			last:    platform.MemoryEvents{},
+			current: platform.MemoryEvents{OOM: 1},
+			events:  []*Event{{Type: EventOOM, ID: "test"}},
+		},
+		"test oom and oom kill": {
+			last:    platform.MemoryEvents{OOM: 1},
+			current: platform.MemoryEvents{OOM: 2, OOMKill: 1},
+			events: []*Event{
+				{Type: EventOOM, ID: "test"},
+				{Type: EventOOMKill, ID: "test"},
+			},
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(
+				t,
+				data.events,
+				memoryEventsSince("test", data.last, data.current),
+			)
+		})
+	}
+}
@@ -2,8 +2,11 @@ package container
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -12,6 +15,7 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/nixpig/anocir/internal/container/ipc"
 	"github.com/nixpig/anocir/internal/platform"
 	"github.com/nixpig/anocir/internal/terminal"
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -22,6 +26,10 @@ import (
 // file descriptor to the child exec process.
 const EnvSeccompFD = "_ANOCIR_SECCOMP_FD"
 
+// EnvSeccompListenerFD is the name of the environment variable used to pass
+// the socket for sending the seccomp notify fd to the child exec process.
+const EnvSeccompListenerFD = "_ANOCIR_SECCOMP_LISTENER_FD"
+
 // ExecOpts holds the options for executing a command in an existing container.
 type ExecOpts struct {
 	Cwd            string
@@ -42,6 +50,16 @@ type ExecOpts struct {
 	ProcessLabel   string
 	PreserveFDs    int
 	Cgroup         string
+	// CgroupResources, if set, is applied to a sub-cgroup of the container
+	// cgroup created for the process, at Cgroup or exec-<pid> if it's empty.
+	// The sub-cgroup is removed once the process exits, unless detached.
+	CgroupResources *specs.LinuxResources
+	// ContainerCgroup is the cgroup of the container. It's only used with
+	// CgroupResources.
+	ContainerCgroup platform.Cgroup
+	// ContainerState is the state of the container, sent to the seccomp agent
+	// if Seccomp uses SCMP_ACT_NOTIFY.
+	ContainerState *specs.State
 }
 
 // Namespaces need to be applied in a specific order. Don't change these.
@@ -122,7 +140,9 @@ func Exec(containerPID int, opts *ExecOpts) (int, error) {
 		args = append(args, "--process-label", opts.ProcessLabel)
 	}
 
-	if opts.Cgroup != "" {
+	// With CgroupResources, the process is started in its sub-cgroup rather
+	// than moving itself to it.
+	if opts.Cgroup != "" && opts.CgroupResources == nil {
 		args = append(args, "--cgroup", opts.Cgroup)
 	}
 
@@ -250,6 +270,29 @@ func Exec(containerPID int, opts *ExecOpts) (int, error) {
 		procAttr.Env = append(procAttr.Env, fmt.Sprintf("%s=%d", EnvSeccompFD, seccompFD))
 	}
 
+	var seccompConn net.Conn
+	var seccompChild *os.File
+	if platform.UsesSeccompNotify(opts.Seccomp) {
+		parent, child, err := ipc.NewSocketPair()
+		if err != nil {
+			return 0, fmt.Errorf("create seccomp listener socket pair: %w", err)
+		}
+		seccompChild = child
+		defer seccompChild.Close()
+
+		seccompConn, err = net.FileConn(parent)
+		parent.Close()
+		if err != nil {
+			return 0, fmt.Errorf("seccomp listener sock file conn: %w", err)
+		}
+		defer seccompConn.Close()
+
+		procAttr.Files = append(procAttr.Files, child.Fd())
+
+		listenerFD := len(procAttr.Files) - 1
+		procAttr.Env = append(procAttr.Env, fmt.Sprintf("%s=%d", EnvSeccompListenerFD, listenerFD))
+	}
+
 	// Check if executable exists in the container's filesystem before forking.
 	// This allows returning an error synchronously (as required by containerd)
 	// rather than deferring failure to later.
@@ -258,6 +301,43 @@ func Exec(containerPID int, opts *ExecOpts) (int, error) {
 		return 0, fmt.Errorf("check executable in container: %w", err)
 	}
 
+	var execCgroup string
+	if opts.CgroupResources != nil {
+		execCgroup = opts.Cgroup
+		if execCgroup == "" {
+			execCgroup = fmt.Sprintf("exec-%d", os.Getpid())
+		}
+
+		cgroupDir, err := platform.CreateExecCgroup(
+			opts.ContainerCgroup,
+			execCgroup,
+			opts.CgroupResources,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("create exec cgroup: %w", err)
+		}
+		defer func() {
+			if err := cgroupDir.Close(); err != nil {
+				slog.Warn("failed to close exec cgroup", "container_id", opts.ContainerID, "err", err)
+			}
+		}()
+
+		// The process is started in the sub-cgroup, so it's never run
+		// without its limits.
+		procAttr.Sys.UseCgroupFD = true
+		procAttr.Sys.CgroupFD = int(cgroupDir.Fd())
+	}
+
+	deleteExecCgroup := func() {
+		if execCgroup == "" {
+			return
+		}
+
+		if err := platform.DeleteExecCgroup(opts.ContainerCgroup, execCgroup); err != nil {
+			slog.Warn("failed to delete exec cgroup", "container_id", opts.ContainerID, "cgroup", execCgroup, "err", err)
+		}
+	}
+
 	execArgs := append([]string{"/proc/self/exe"}, args...)
 
 	slog.Debug(
@@ -270,10 +350,28 @@ func Exec(containerPID int, opts *ExecOpts) (int, error) {
 	)
 
 	pid, err := syscall.ForkExec(execArgs[0], execArgs, procAttr)
+
+	// Only the child holds its end of the socket now, so the parent sees it
+	// closed once the child execs or exits.
+	if seccompChild != nil {
+		seccompChild.Close()
+	}
+
 	if err != nil {
+		deleteExecCgroup()
 		return 0, fmt.Errorf("reexec child process: %w", err)
 	}
 
+	if !opts.Detach {
+		defer deleteExecCgroup()
+	}
+
+	if seccompConn != nil {
+		if err := receiveSeccompListener(seccompConn, opts, pid); err != nil {
+			return 0, err
+		}
+	}
+
 	if opts.PIDFile != "" {
 		if err := os.WriteFile(opts.PIDFile, strconv.AppendInt(nil, int64(pid), 10), 0o644); err != nil {
 			return 0, fmt.Errorf("write pid to file (%s): %w", opts.PIDFile, err)
@@ -302,6 +400,24 @@ func Exec(containerPID int, opts *ExecOpts) (int, error) {
 	return 0, nil
 }
 
+// receiveSeccompListener passes the seccomp notify fd of the child exec
+// process with the given pid to the seccomp agent, if it sends one before it
+// execs or exits.
+func receiveSeccompListener(conn net.Conn, opts *ExecOpts, pid int) error {
+	// The child closes its end of the socket when it execs, rather than
+	// sending another message.
+	if _, err := receiveMessage(
+		conn,
+		opts.Seccomp,
+		opts.ContainerState,
+		pid,
+	); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("receive seccomp listener: %w", err)
+	}
+
+	return nil
+}
+
 // sharedNamespace determines whether the host and container share a namespace
 // by checking whether the containerNSPath and hostNSPath are the same file.
 func sharedNamespace(containerNSPath, hostNSPath string) (bool, error) {
@@ -117,7 +117,7 @@ func GetFeatures() *Features {
 				V1:          false,
 				V2:          true,
 				Systemd:     true,
-				SystemdUser: false,
+				SystemdUser: true,
 				RDMA:        false,
 			},
 			Seccomp: &SeccompFeatures{
@@ -0,0 +1,312 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/nixpig/anocir/internal/container/ipc"
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// gcMinAge is the minimum age of a container directory without a state file
+// before it's considered stale, so containers being created aren't collected.
+const gcMinAge = time.Minute
+
+// socketDirPattern matches the names of the socket directories created by
+// containerSockPath.
+var socketDirPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// StaleKind is the kind of a StaleResource.
+type StaleKind string
+
+const (
+	// StaleContainer is a container directory whose process is gone.
+	StaleContainer StaleKind = "container"
+	// StaleSocketDir is a socket directory no container refers to.
+	StaleSocketDir StaleKind = "socket"
+	// StaleCgroup is a container cgroup without a container.
+	StaleCgroup StaleKind = "cgroup"
+	// StaleExecCgroup is an exec sub-cgroup of a container cgroup left behind
+	// by a detached exec process that has exited.
+	StaleExecCgroup StaleKind = "exec-cgroup"
+)
+
+// StaleResource is a resource left behind by a container that no longer
+// exists or whose process is gone.
+type StaleResource struct {
+	Kind StaleKind `json:"kind"`
+	// ID is the ID of the container the resource belongs to, if known.
+	ID     string `json:"id,omitempty"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+	// Removed is true once the resource has been removed.
+	Removed bool `json:"removed"`
+	// Error is the reason the resource couldn't be removed, if any.
+	Error string `json:"error,omitempty"`
+
+	// cgroup identifies a StaleCgroup.
+	cgroup platform.Cgroup
+}
+
+// FindStale cross-references the containers in rootDir with the container
+// socket directories and cgroups, and returns the resources that are stale.
+// Only containers in rootDir are considered, so resources belonging to
+// containers in another root directory are reported as stale. Cgroups without
+// a container that still have processes may belong to a container in another
+// root directory, so they're only reported if force is true.
+func FindStale(rootDir string, force bool) ([]*StaleResource, error) {
+	cgroups, err := platform.FindContainerCgroups()
+	if err != nil {
+		return nil, fmt.Errorf("find container cgroups: %w", err)
+	}
+
+	return findStale(rootDir, platform.RuntimeDir(), cgroups, force)
+}
+
+func findStale(
+	rootDir, socketDir string,
+	cgroups []platform.ContainerCgroup,
+	force bool,
+) ([]*StaleResource, error) {
+	stale := []*StaleResource{}
+
+	entries, err := os.ReadDir(rootDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read root directory: %w", err)
+	}
+
+	containerIDs := make(map[string]bool)
+	socketDirs := make(map[string]bool)
+
+	for _, entry := range entries {
+		dir := filepath.Join(rootDir, entry.Name())
+
+		if !entry.IsDir() || !isContainerDir(dir) {
+			continue
+		}
+
+		containerIDs[entry.Name()] = true
+
+		resource, bundle := checkContainer(entry.Name(), rootDir)
+		if bundle != "" {
+			socketDirs[ipc.ShortID(bundle)] = true
+		}
+
+		if resource != nil {
+			stale = append(stale, resource)
+		}
+	}
+
+	entries, err = os.ReadDir(socketDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read socket directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		// The socket directory and root directory may be the same.
+		if !entry.IsDir() ||
+			!socketDirPattern.MatchString(name) ||
+			containerIDs[name] ||
+			socketDirs[name] {
+			continue
+		}
+
+		dir := filepath.Join(socketDir, name)
+		if !isSocketDir(dir) {
+			continue
+		}
+
+		stale = append(stale, &StaleResource{
+			Kind:   StaleSocketDir,
+			Path:   dir,
+			Reason: "no container refers to it",
+		})
+	}
+
+	for _, cg := range cgroups {
+		if containerIDs[cg.Cgroup.ContainerID] {
+			stale = append(stale, findStaleExecCgroups(cg)...)
+			continue
+		}
+
+		if cg.Processes > 0 && !force {
+			slog.Debug("skip populated cgroup without container", "container_id", cg.Cgroup.ContainerID, "path", cg.Path, "processes", cg.Processes)
+			continue
+		}
+
+		stale = append(stale, &StaleResource{
+			Kind:   StaleCgroup,
+			ID:     cg.Cgroup.ContainerID,
+			Path:   cg.Path,
+			Reason: fmt.Sprintf("no container state, %d processes", cg.Processes),
+			cgroup: cg.Cgroup,
+		})
+	}
+
+	return stale, nil
+}
+
+// findStaleExecCgroups returns the exec sub-cgroups of the given container
+// cgroup left behind by detached exec processes that have exited.
+func findStaleExecCgroups(cg platform.ContainerCgroup) []*StaleResource {
+	subPaths, err := platform.FindStaleExecCgroups(cg.Path)
+	if err != nil {
+		slog.Debug("failed to find stale exec cgroups", "container_id", cg.Cgroup.ContainerID, "path", cg.Path, "err", err)
+		return nil
+	}
+
+	stale := make([]*StaleResource, 0, len(subPaths))
+	for _, subPath := range subPaths {
+		stale = append(stale, &StaleResource{
+			Kind:   StaleExecCgroup,
+			ID:     cg.Cgroup.ContainerID,
+			Path:   filepath.Join(cg.Path, subPath),
+			Reason: "exec process exited",
+		})
+	}
+
+	return stale
+}
+
+// RemoveStale removes the given stale resources, recording the outcome in
+// each. Stale containers are deleted, including running their poststop hooks,
+// where their spec is still available.
+func RemoveStale(rootDir string, stale []*StaleResource) {
+	for _, resource := range stale {
+		slog.Debug("remove stale resource", "kind", resource.Kind, "container_id", resource.ID, "path", resource.Path)
+
+		if err := removeStale(rootDir, resource); err != nil {
+			slog.Warn("failed to remove stale resource", "kind", resource.Kind, "container_id", resource.ID, "path", resource.Path, "err", err)
+			resource.Error = err.Error()
+			continue
+		}
+
+		resource.Removed = true
+	}
+}
+
+func removeStale(rootDir string, resource *StaleResource) error {
+	switch resource.Kind {
+	case StaleContainer:
+		c, err := Load(resource.ID, rootDir)
+		if err != nil {
+			// Without a state or spec the container can't be deleted, so just
+			// remove what's left of it.
+			return os.RemoveAll(resource.Path)
+		}
+
+		return c.Delete(true)
+	case StaleSocketDir:
+		return os.RemoveAll(resource.Path)
+	case StaleCgroup:
+		return platform.DeleteCgroup(resource.cgroup)
+	case StaleExecCgroup:
+		// Removing a cgroup fails if a process has joined it since it was
+		// found, so it's never taken from under one.
+		return os.Remove(resource.Path)
+	default:
+		return fmt.Errorf("unknown stale resource kind: %s", resource.Kind)
+	}
+}
+
+// checkContainer returns a StaleResource if the container with the given id in
+// rootDir is stale, along with the container bundle, if known.
+func checkContainer(id, rootDir string) (*StaleResource, string) {
+	dir := filepath.Join(rootDir, id)
+	resource := &StaleResource{Kind: StaleContainer, ID: id, Path: dir}
+
+	data, err := os.ReadFile(filepath.Join(dir, "state.json"))
+	if err != nil {
+		info, statErr := os.Stat(dir)
+		if statErr != nil || time.Since(info.ModTime()) < gcMinAge {
+			return nil, ""
+		}
+
+		resource.Reason = fmt.Sprintf("no container state: %s", err)
+		return resource, ""
+	}
+
+	var persisted persistedState
+	if err := json.Unmarshal(data, &persisted); err != nil || persisted.State == nil {
+		resource.Reason = "invalid container state"
+		return resource, ""
+	}
+
+	c := &Container{
+		State:        persisted.State,
+		RootDir:      rootDir,
+		pidStartTime: persisted.PIDStartTime,
+		monitored:    persisted.Monitored,
+	}
+
+	// A locked container is in use, e.g. still being created.
+	if err := c.lock(unix.LOCK_EX, 0); err != nil {
+		return nil, c.State.Bundle
+	}
+	defer c.Unlock()
+
+	if c.State.Pid == 0 {
+		if c.State.Status != specs.StateCreating {
+			return nil, c.State.Bundle
+		}
+
+		resource.Reason = "create failed"
+		return resource, c.State.Bundle
+	}
+
+	dead, err := c.isProcessDead()
+	if err != nil || !dead {
+		return nil, c.State.Bundle
+	}
+
+	// When the container process exits normally the monitor records its exit
+	// status, and the container is waiting to be deleted. Without a monitor
+	// a stopped container can't be told apart from one that was orphaned.
+	if !c.monitored {
+		return nil, c.State.Bundle
+	}
+
+	if status, err := c.GetExitStatus(); err != nil || status != nil {
+		return nil, c.State.Bundle
+	}
+
+	resource.Reason = "container process is gone and no exit status was recorded"
+
+	return resource, c.State.Bundle
+}
+
+// isSocketDir reports whether dir looks like an unused container socket
+// directory, i.e. it contains nothing but a container socket that isn't being
+// listened on.
+func isSocketDir(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		if entry.Name() != containerSockFilename {
+			return false
+		}
+	}
+
+	// Connecting to the socket to check it would be taken as the start
+	// connection by a created container, so check the listening sockets.
+	listening, err := platform.IsUnixSocketListening(filepath.Join(dir, containerSockFilename))
+	if err != nil {
+		slog.Debug("failed to check if socket is listening", "path", dir, "err", err)
+		return false
+	}
+
+	return !listening
+}
@@ -0,0 +1,158 @@
+package container
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nixpig/anocir/internal/container/ipc"
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindStale(t *testing.T) {
+	t.Parallel()
+
+	rootDir := t.TempDir()
+	socketDir := t.TempDir()
+
+	startTime, err := platform.ProcessStartTime(os.Getpid())
+	require.NoError(t, err)
+
+	newContainer := func(id string, status specs.ContainerState, pid int) *Container {
+		c, err := New(&Opts{
+			ID:      id,
+			Bundle:  filepath.Join(t.TempDir(), id),
+			Spec:    &specs.Spec{Linux: &specs.Linux{}},
+			RootDir: rootDir,
+			Monitor: id != "unmonitored",
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, os.MkdirAll(c.containerDir(), 0o755))
+
+		c.State.Status = status
+		c.State.Pid = pid
+		if pid == os.Getpid() {
+			c.pidStartTime = startTime
+		}
+		require.NoError(t, c.Save())
+
+		return c
+	}
+
+	newContainer("failed-create", specs.StateCreating, 0)
+	running := newContainer("running", specs.StateRunning, os.Getpid())
+	newContainer("orphaned", specs.StateRunning, 1<<30)
+	exited := newContainer("exited", specs.StateRunning, 1<<30)
+	require.NoError(t, exited.saveExitStatus(&ExitStatus{ExitCode: 0}))
+	newContainer("unmonitored", specs.StateRunning, 1<<30)
+
+	for _, name := range []string{
+		ipc.ShortID(running.State.Bundle),
+		"0123456789abcdef",
+	} {
+		require.NoError(t, os.Mkdir(filepath.Join(socketDir, name), 0o755))
+	}
+
+	runningCgroup := t.TempDir()
+	for name, procs := range map[string]string{
+		"exec-1073741824": "",
+		"exec-1073741825": "1234\n",
+	} {
+		require.NoError(t, os.Mkdir(filepath.Join(runningCgroup, name), 0o755))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(runningCgroup, name, "cgroup.procs"),
+			[]byte(procs),
+			0o644,
+		))
+	}
+
+	cgroups := []platform.ContainerCgroup{
+		{
+			Path:   runningCgroup,
+			Cgroup: platform.Cgroup{Driver: platform.SystemdDriver, ContainerID: "running"},
+		},
+		{
+			Path:   "/sys/fs/cgroup/system.slice/anocir-gone.scope",
+			Cgroup: platform.Cgroup{Driver: platform.SystemdDriver, ContainerID: "gone"},
+		},
+		{
+			Path:   "/sys/fs/cgroup/anocir/gone-too",
+			Cgroup: platform.Cgroup{Driver: platform.CgroupfsDriver, ContainerID: "gone-too"},
+		},
+		{
+			Path:      "/sys/fs/cgroup/anocir/other-root",
+			Cgroup:    platform.Cgroup{Driver: platform.CgroupfsDriver, ContainerID: "other-root"},
+			Processes: 2,
+		},
+	}
+
+	want := map[string]StaleKind{
+		filepath.Join(rootDir, "failed-create"):         StaleContainer,
+		filepath.Join(rootDir, "orphaned"):              StaleContainer,
+		filepath.Join(socketDir, "0123456789abcdef"):    StaleSocketDir,
+		"/sys/fs/cgroup/system.slice/anocir-gone.scope": StaleCgroup,
+		"/sys/fs/cgroup/anocir/gone-too":                StaleCgroup,
+		filepath.Join(runningCgroup, "exec-1073741824"): StaleExecCgroup,
+	}
+
+	scenarios := map[string]struct {
+		force bool
+		want  map[string]StaleKind
+	}{
+		"test skips populated cgroups": {
+			force: false,
+			want:  want,
+		},
+		"test includes populated cgroups with force": {
+			force: true,
+			want: func() map[string]StaleKind {
+				w := maps.Clone(want)
+				w["/sys/fs/cgroup/anocir/other-root"] = StaleCgroup
+				return w
+			}(),
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			stale, err := findStale(rootDir, socketDir, cgroups, data.force)
+			require.NoError(t, err)
+
+			got := make(map[string]StaleKind)
+			for _, s := range stale {
+				got[s.Path] = s.Kind
+			}
+
+			assert.Equal(t, data.want, got)
+		})
+	}
+}
+
+func TestRemoveStale(t *testing.T) {
+	t.Parallel()
+
+	c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+	socketDir := filepath.Join(t.TempDir(), "0123456789abcdef")
+	require.NoError(t, os.Mkdir(socketDir, 0o755))
+	execCgroup := filepath.Join(t.TempDir(), "exec-1073741824")
+	require.NoError(t, os.Mkdir(execCgroup, 0o755))
+
+	stale := []*StaleResource{
+		{Kind: StaleContainer, ID: c.State.ID, Path: c.containerDir()},
+		{Kind: StaleSocketDir, Path: socketDir},
+		{Kind: StaleExecCgroup, ID: c.State.ID, Path: execCgroup},
+	}
+
+	RemoveStale(c.RootDir, stale)
+
+	for _, s := range stale {
+		assert.True(t, s.Removed)
+		assert.Empty(t, s.Error)
+		assert.NoDirExists(t, s.Path)
+	}
+}
@@ -0,0 +1,92 @@
+package container
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// Inspection is the full runtime view of a container, for troubleshooting.
+type Inspection struct {
+	State *specs.State `json:"state"`
+	Exit  *ExitStatus  `json:"exit,omitempty"`
+	// Spec is the effective spec, i.e. the snapshot of the bundle config taken
+	// when the container was created.
+	Spec   *specs.Spec          `json:"spec"`
+	RootFS string               `json:"rootfs"`
+	Cgroup *platform.CgroupInfo `json:"cgroup,omitempty"`
+	// Namespaces is the inode number of each namespace of the container
+	// process, keyed by type. It's only set while the process is alive.
+	Namespaces map[string]uint64 `json:"namespaces,omitempty"`
+	// Mounts is the mounts in the mount namespace of the container process.
+	// It's only set while the process is alive.
+	Mounts        []platform.MountInfo `json:"mounts,omitempty"`
+	ConsoleSocket string               `json:"consoleSocket,omitempty"`
+	PIDFile       string               `json:"pidFile,omitempty"`
+	// Socket is the socket used to start the container.
+	Socket string `json:"socket"`
+	// Dir is the directory the runtime keeps the container state in.
+	Dir      string    `json:"dir"`
+	Created  time.Time `json:"created,omitzero"`
+	Finished time.Time `json:"finished,omitzero"`
+}
+
+// Inspect returns the Inspection of the container. Details that can't be read,
+// e.g. because the container process exited, are left out.
+func (c *Container) Inspect() (*Inspection, error) {
+	state, err := c.GetState()
+	if err != nil {
+		return nil, fmt.Errorf("get container state: %w", err)
+	}
+
+	exitStatus, err := c.GetExitStatus()
+	if err != nil {
+		return nil, fmt.Errorf("get container exit status: %w", err)
+	}
+
+	inspection := &Inspection{
+		State:         state,
+		Exit:          exitStatus,
+		Spec:          c.spec,
+		RootFS:        c.rootFS(),
+		ConsoleSocket: c.ConsoleSocket,
+		PIDFile:       c.pidFile,
+		Socket:        c.containerSock,
+		Dir:           c.containerDir(),
+		Created:       c.created,
+	}
+
+	if exitStatus != nil {
+		inspection.Finished = exitStatus.FinishedAt
+	}
+
+	if cgroup, err := platform.GetCgroupInfo(c.cgroup()); err != nil {
+		slog.Debug("failed to get cgroup info", "container_id", state.ID, "err", err)
+	} else {
+		inspection.Cgroup = cgroup
+	}
+
+	if state.Pid == 0 || state.Status == specs.StateStopped {
+		return inspection, nil
+	}
+
+	if inspection.Namespaces, err = platform.GetNamespaceInodes(state.Pid); err != nil {
+		slog.Debug("failed to get namespaces", "container_id", state.ID, "pid", state.Pid, "err", err)
+	}
+
+	if inspection.Mounts, err = platform.GetMountInfo(state.Pid); err != nil {
+		slog.Debug("failed to get mounts", "container_id", state.ID, "pid", state.Pid, "err", err)
+	}
+
+	// The process may have exited, and its PID been reused, while the details
+	// were read.
+	if alive, err := platform.IsProcessAlive(state.Pid, c.pidStartTime); err != nil || !alive {
+		inspection.Namespaces = nil
+		inspection.Mounts = nil
+	}
+
+	return inspection, nil
+}
@@ -0,0 +1,73 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+
+	t.Run("test created container", func(t *testing.T) {
+		t.Parallel()
+
+		c := newTestContainer(t, &specs.Spec{
+			Root:  &specs.Root{Path: "rootfs"},
+			Linux: &specs.Linux{},
+		})
+
+		startTime, err := platform.ProcessStartTime(os.Getpid())
+		require.NoError(t, err)
+
+		c.State.Pid = os.Getpid()
+		c.State.Status = specs.StateCreated
+		c.pidStartTime = startTime
+		c.rootfs = c.rootFS()
+		require.NoError(t, c.saveConfig())
+		require.NoError(t, c.Save())
+
+		loaded, err := Load(c.State.ID, c.RootDir)
+		require.NoError(t, err)
+
+		inspection, err := loaded.Inspect()
+		require.NoError(t, err)
+
+		assert.Equal(t, specs.StateCreated, inspection.State.Status)
+		assert.Equal(t, filepath.Join(c.State.Bundle, "rootfs"), inspection.RootFS)
+		assert.Equal(t, c.pidFile, inspection.PIDFile)
+		assert.Equal(t, containerSockPath(c.State.Bundle), inspection.Socket)
+		assert.Equal(t, c.containerDir(), inspection.Dir)
+		assert.Equal(t, c.GetCreated(), inspection.Created)
+		assert.Equal(t, "anocir-test-container.scope", inspection.Cgroup.Scope)
+		assert.Contains(t, inspection.Namespaces, "mnt")
+		assert.NotEmpty(t, inspection.Mounts)
+	})
+
+	t.Run("test stopped container", func(t *testing.T) {
+		t.Parallel()
+
+		c := newTestContainer(t, &specs.Spec{
+			Root:  &specs.Root{Path: "rootfs"},
+			Linux: &specs.Linux{},
+		})
+
+		c.State.Pid = os.Getpid()
+		c.State.Status = specs.StateRunning
+		// A different start time means the process has gone.
+		c.pidStartTime = 1
+		require.NoError(t, c.Save())
+
+		inspection, err := c.Inspect()
+		require.NoError(t, err)
+
+		assert.Equal(t, specs.StateStopped, inspection.State.Status)
+		assert.Nil(t, inspection.Namespaces)
+		assert.Nil(t, inspection.Mounts)
+	})
+}
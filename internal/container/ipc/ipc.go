@@ -3,16 +3,41 @@
 package ipc
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"os"
+	"syscall"
 
 	"golang.org/x/sys/unix"
 )
 
+const (
+	// ProtocolVersion is the version of the message framing and payloads. It's
+	// incremented whenever either changes incompatibly. The message values
+	// are part of the protocol, so new messages are appended rather than
+	// changing existing values, which would need a new version.
+	ProtocolVersion byte = 1
+
+	// headerSize is the size of the message header: the protocol version, the
+	// message type and the big-endian uint32 payload length.
+	headerSize = 6
+
+	// maxPayloadSize is the maximum size of a message payload.
+	maxPayloadSize = 1 << 20
+)
+
+// ErrProtocolVersion is returned when a message is received with a different
+// protocol version, e.g. from a container created by another runtime version.
+var ErrProtocolVersion = errors.New("unsupported ipc protocol version")
+
 const (
 	// MsgStart is the message sent on the container socket to start the created
 	// container.
@@ -22,8 +47,10 @@ const (
 	// is created and ready to receive commands.
 	MsgReady
 
-	// MsgInvalidExecutable is the message sent over the init socketpair when the
-	// user executable cannot be found.
+	// MsgInvalidExecutable was the message sent over the init socketpair when
+	// the user executable couldn't be found. It's no longer sent, since a
+	// MsgError describes the failure, but its value is kept so the values
+	// of the other messages stay the same within this ProtocolVersion.
 	MsgInvalidExecutable
 
 	// MsgPrePivot is the message sent before pivot_root is called.
@@ -32,8 +59,85 @@ const (
 	// MsgExecReady is the message sent right before execve to indicate the
 	// container is fully initialized and about to execute the user process.
 	MsgExecReady
+
+	// MsgError is the message sent when the container fails to initialise or
+	// start. Its payload is an Error.
+	MsgError
+
+	// MsgIDMapped is the message sent over the init socketpair when the user
+	// namespace mappings of a rootless container process have been written.
+	MsgIDMapped
+
+	// MsgSeccompListener is the message sent by a container process, with its
+	// seccomp notify fd attached, once its seccomp filter is loaded.
+	MsgSeccompListener
+
+	// MsgSeccompListenerSent is the message sent to a container process once
+	// its seccomp notify fd has been sent to the seccomp agent.
+	MsgSeccompListenerSent
 )
 
+// Message is a single framed message.
+type Message struct {
+	Type    byte
+	Payload json.RawMessage
+}
+
+// Error is the payload of a MsgError message. It describes where and why the
+// container process failed, so the cause can be returned to the caller rather
+// than only being logged by the container process.
+type Error struct {
+	// Phase is the stage of container setup that failed, e.g. "setup
+	// pre-pivot".
+	Phase string `json:"phase"`
+	// Operation is the failed operation, e.g. "mount" or "mkdir", if known.
+	Operation string `json:"operation,omitempty"`
+	// Path is the path the failed operation acted on, if any.
+	Path string `json:"path,omitempty"`
+	// Errno is the errno of the failed syscall, if any.
+	Errno syscall.Errno `json:"errno,omitempty"`
+	// Message is the full error message.
+	Message string `json:"message"`
+}
+
+// NewError creates an Error for the given phase from err, extracting the
+// operation, path and errno from the error chain where available.
+func NewError(phase string, err error) *Error {
+	e := &Error{Phase: phase, Message: err.Error()}
+
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	var syscallErr *os.SyscallError
+
+	switch {
+	case errors.As(err, &pathErr):
+		e.Operation = pathErr.Op
+		e.Path = pathErr.Path
+	case errors.As(err, &linkErr):
+		e.Operation = linkErr.Op
+		e.Path = linkErr.New
+	case errors.As(err, &syscallErr):
+		e.Operation = syscallErr.Syscall
+	}
+
+	errors.As(err, &e.Errno)
+
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Phase + ": " + e.Message
+}
+
+// Unwrap returns the Errno, if any, so callers can check it with errors.Is.
+func (e *Error) Unwrap() error {
+	if e.Errno == 0 {
+		return nil
+	}
+
+	return e.Errno
+}
+
 // Socket holds a path to use for a unix domain socket.
 type Socket struct {
 	path string
@@ -54,22 +158,199 @@ func (s *Socket) Dial() (net.Conn, error) {
 	return net.Dial("unix", s.path)
 }
 
-// SendMessage writes the given msg to the given conn.
-func SendMessage(conn net.Conn, msg byte) error {
-	_, err := conn.Write([]byte{msg})
+// WriteMessage writes a message of the given msgType to w, with payload
+// encoded as JSON. A nil payload sends an empty payload.
+func WriteMessage(w io.Writer, msgType byte, payload any) error {
+	var data []byte
+
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("marshal payload: %w", err)
+		}
+	}
+
+	if len(data) > maxPayloadSize {
+		return fmt.Errorf("payload too large (%d bytes)", len(data))
+	}
+
+	buf := make([]byte, headerSize, headerSize+len(data))
+	buf[0] = ProtocolVersion
+	buf[1] = msgType
+	binary.BigEndian.PutUint32(buf[2:], uint32(len(data)))
+	buf = append(buf, data...)
+
+	_, err := w.Write(buf)
 
 	return err
 }
 
-// ReceiveMessage reads a single byte from the given conn and returns the read data.
+// ReadMessage reads a single message from r.
+func ReadMessage(r io.Reader) (*Message, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	if header[0] != ProtocolVersion {
+		return nil, fmt.Errorf(
+			"%w: got %d, expected %d",
+			ErrProtocolVersion,
+			header[0],
+			ProtocolVersion,
+		)
+	}
+
+	size := binary.BigEndian.Uint32(header[2:])
+	if size > maxPayloadSize {
+		return nil, fmt.Errorf("payload too large (%d bytes)", size)
+	}
+
+	msg := &Message{Type: header[1]}
+
+	if size > 0 {
+		msg.Payload = make([]byte, size)
+		if _, err := io.ReadFull(r, msg.Payload); err != nil {
+			return nil, fmt.Errorf("read payload: %w", err)
+		}
+	}
+
+	return msg, nil
+}
+
+// SendMessage writes the given msg, without a payload, to the given conn.
+func SendMessage(conn net.Conn, msg byte) error {
+	return WriteMessage(conn, msg, nil)
+}
+
+// SendError writes a MsgError with an Error for the given phase and err to the
+// given conn. If err already is, or wraps, an Error, then it's sent as is.
+func SendError(conn net.Conn, phase string, err error) error {
+	var ipcErr *Error
+	if !errors.As(err, &ipcErr) {
+		ipcErr = NewError(phase, err)
+	}
+
+	return WriteMessage(conn, MsgError, ipcErr)
+}
+
+// ReceiveMessage reads a single message from the given conn and returns its
+// type. If a MsgError is received, then its Error is returned.
 func ReceiveMessage(conn net.Conn) (byte, error) {
-	buf := make([]byte, 1)
-	_, err := io.ReadFull(conn, buf)
+	return receiveMessage(conn)
+}
+
+func receiveMessage(r io.Reader) (byte, error) {
+	msg, err := ReadMessage(r)
 	if err != nil {
 		return 0, err
 	}
 
-	return buf[0], nil
+	if msg.Type == MsgError {
+		var ipcErr Error
+		if err := json.Unmarshal(msg.Payload, &ipcErr); err != nil {
+			return 0, fmt.Errorf("unmarshal error payload: %w", err)
+		}
+
+		return 0, &ipcErr
+	}
+
+	return msg.Type, nil
+}
+
+// SendFile writes a message of the given msgType, without a payload, to the
+// given conn, with f attached as SCM_RIGHTS.
+func SendFile(conn net.Conn, msgType byte, f *os.File) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("files can only be sent on unix sockets")
+	}
+
+	header := make([]byte, headerSize)
+	header[0] = ProtocolVersion
+	header[1] = msgType
+
+	_, _, err := unixConn.WriteMsgUnix(header, unix.UnixRights(int(f.Fd())), nil)
+
+	return err
+}
+
+// ReceiveMessageFile reads a single message from the given conn, like
+// ReceiveMessage, and returns the file attached to it, if any, which the
+// caller must close.
+func ReceiveMessageFile(conn net.Conn) (byte, *os.File, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, nil, errors.New("files can only be received on unix sockets")
+	}
+
+	// The attached file arrives with the first byte of the message, so the
+	// header is read with it and the rest of the message as normal.
+	header := make([]byte, headerSize)
+	oob := make([]byte, unix.CmsgSpace(4))
+
+	n, oobn, _, _, err := unixConn.ReadMsgUnix(header, oob)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if n == 0 {
+		return 0, nil, io.EOF
+	}
+
+	f, err := parseRights(oob[:oobn])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	msgType, err := receiveMessage(
+		io.MultiReader(bytes.NewReader(header[:n]), conn),
+	)
+	if err != nil {
+		if f != nil {
+			f.Close()
+		}
+
+		return 0, nil, err
+	}
+
+	return msgType, f, nil
+}
+
+// parseRights returns the file passed in the given SCM_RIGHTS control
+// message, or nil if there isn't one.
+func parseRights(oob []byte) (*os.File, error) {
+	if len(oob) == 0 {
+		return nil, nil
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, fmt.Errorf("parse control message: %w", err)
+	}
+
+	var fds []int
+	for _, msg := range msgs {
+		rights, err := unix.ParseUnixRights(&msg)
+		if err != nil {
+			continue
+		}
+
+		fds = append(fds, rights...)
+	}
+
+	if len(fds) == 0 {
+		return nil, nil
+	}
+
+	// Only one file is ever sent, so any others are closed.
+	for _, fd := range fds[1:] {
+		unix.Close(fd)
+	}
+
+	unix.CloseOnExec(fds[0])
+
+	return os.NewFile(uintptr(fds[0]), "ipc_file"), nil
 }
 
 // NewSocketPair creates a socket pair and returns the file descriptors.
@@ -1,11 +1,17 @@
 package ipc
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"net"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 )
 
 func TestIPCSocket(t *testing.T) {
@@ -60,6 +66,154 @@ func TestIPCSocketPair(t *testing.T) {
 	assert.Equal(t, MsgExecReady, msg)
 }
 
+func TestMessageFraming(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		msgType byte
+		payload any
+		want    string
+	}{
+		"test message without payload": {
+			msgType: MsgReady,
+			payload: nil,
+			want:    "",
+		},
+		"test message with payload": {
+			msgType: MsgError,
+			payload: &Error{Phase: "setup", Message: "failed"},
+			want:    `{"phase":"setup","message":"failed"}`,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			require.NoError(t, WriteMessage(&buf, data.msgType, data.payload))
+
+			msg, err := ReadMessage(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, data.msgType, msg.Type)
+			assert.Equal(t, data.want, string(msg.Payload))
+			assert.Zero(t, buf.Len())
+		})
+	}
+}
+
+func TestReadMessageInvalid(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"test unsupported version": {
+			data: []byte{ProtocolVersion + 1, MsgReady, 0, 0, 0, 0},
+			err:  ErrProtocolVersion,
+		},
+		"test truncated header": {
+			data: []byte{ProtocolVersion, MsgReady},
+		},
+		"test truncated payload": {
+			data: []byte{ProtocolVersion, MsgError, 0, 0, 0, 4, '{'},
+		},
+		"test payload too large": {
+			data: []byte{ProtocolVersion, MsgError, 0xff, 0xff, 0xff, 0xff},
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ReadMessage(bytes.NewReader(data.data))
+			assert.Error(t, err)
+
+			if data.err != nil {
+				assert.ErrorIs(t, err, data.err)
+			}
+		})
+	}
+}
+
+func TestNewError(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		err       error
+		operation string
+		path      string
+		errno     unix.Errno
+	}{
+		"test path error": {
+			err: fmt.Errorf("mount rootfs: %w", &os.PathError{
+				Op:   "mount",
+				Path: "/rootfs",
+				Err:  unix.EPERM,
+			}),
+			operation: "mount",
+			path:      "/rootfs",
+			errno:     unix.EPERM,
+		},
+		"test syscall error": {
+			err:       fmt.Errorf("set hostname: %w", os.NewSyscallError("sethostname", unix.EINVAL)),
+			operation: "sethostname",
+			errno:     unix.EINVAL,
+		},
+		"test plain error": {
+			err: errors.New("failed"),
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			ipcErr := NewError("setup pre-pivot", data.err)
+
+			assert.Equal(t, "setup pre-pivot", ipcErr.Phase)
+			assert.Equal(t, data.operation, ipcErr.Operation)
+			assert.Equal(t, data.path, ipcErr.Path)
+			assert.Equal(t, data.errno, ipcErr.Errno)
+			assert.Equal(t, "setup pre-pivot: "+data.err.Error(), ipcErr.Error())
+
+			if data.errno != 0 {
+				assert.ErrorIs(t, ipcErr, data.errno)
+			}
+		})
+	}
+}
+
+func TestSendError(t *testing.T) {
+	receiveFile, sendFile, err := NewSocketPair()
+	require.NoError(t, err)
+
+	receiveConn, err := net.FileConn(receiveFile)
+	require.NoError(t, err)
+	defer receiveConn.Close()
+
+	sendConn, err := net.FileConn(sendFile)
+	require.NoError(t, err)
+	defer sendConn.Close()
+
+	go SendError(sendConn, "setup post-pivot", &os.PathError{
+		Op:   "chdir",
+		Path: "/missing",
+		Err:  unix.ENOENT,
+	})
+
+	_, err = ReceiveMessage(receiveConn)
+
+	var ipcErr *Error
+	require.ErrorAs(t, err, &ipcErr)
+	assert.Equal(t, "setup post-pivot", ipcErr.Phase)
+	assert.Equal(t, "chdir", ipcErr.Operation)
+	assert.Equal(t, "/missing", ipcErr.Path)
+	assert.ErrorIs(t, err, unix.ENOENT)
+}
+
 func TestShortID(t *testing.T) {
 	scenarios := map[string]struct {
 		bundle  string
@@ -93,3 +247,55 @@ func TestShortID(t *testing.T) {
 		})
 	}
 }
+
+func TestSendFile(t *testing.T) {
+	t.Parallel()
+
+	receiveFile, sendFile, err := NewSocketPair()
+	require.NoError(t, err)
+
+	receiveConn, err := net.FileConn(receiveFile)
+	require.NoError(t, err)
+	defer receiveConn.Close()
+
+	sendConn, err := net.FileConn(sendFile)
+	require.NoError(t, err)
+	defer sendConn.Close()
+
+	f, err := os.CreateTemp(t.TempDir(), "file")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString("contents")
+	require.NoError(t, err)
+
+	require.NoError(t, SendFile(sendConn, MsgSeccompListener, f))
+	require.NoError(t, SendMessage(sendConn, MsgExecReady))
+
+	msg, received, err := ReceiveMessageFile(receiveConn)
+	require.NoError(t, err)
+	require.NotNil(t, received)
+	defer received.Close()
+	assert.Equal(t, MsgSeccompListener, msg)
+
+	data, err := os.ReadFile(fmt.Sprintf("/proc/self/fd/%d", received.Fd()))
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(data))
+
+	msg, received, err = ReceiveMessageFile(receiveConn)
+	require.NoError(t, err)
+	assert.Nil(t, received)
+	assert.Equal(t, MsgExecReady, msg)
+}
+
+func TestMessageValues(t *testing.T) {
+	t.Parallel()
+
+	// Changing the values changes the protocol, which needs a new
+	// ProtocolVersion.
+	assert.Equal(t, byte(1), MsgStart)
+	assert.Equal(t, byte(2), MsgReady)
+	assert.Equal(t, byte(3), MsgInvalidExecutable)
+	assert.Equal(t, byte(4), MsgPrePivot)
+	assert.Equal(t, byte(5), MsgExecReady)
+}
@@ -4,11 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 
 	"github.com/nixpig/anocir/internal/container/ipc"
-	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/nixpig/anocir/internal/platform"
 )
 
 // Exists checks if a container exists with the given id at the given rootDir.
@@ -18,6 +19,29 @@ func Exists(id, rootDir string) bool {
 	return err == nil
 }
 
+// List returns the IDs of the containers in the given rootDir.
+func List(rootDir string) ([]string, error) {
+	entries, err := os.ReadDir(rootDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("read root directory: %w", err)
+	}
+
+	var ids []string
+
+	for _, entry := range entries {
+		// The root directory may also contain container socket directories.
+		if entry.IsDir() && isContainerDir(filepath.Join(rootDir, entry.Name())) {
+			ids = append(ids, entry.Name())
+		}
+	}
+
+	return ids, nil
+}
+
 // Load retrieves an existing container with the given id at the given rootDir.
 func Load(id, rootDir string) (*Container, error) {
 	s, err := os.ReadFile(filepath.Join(rootDir, id, "state.json"))
@@ -29,34 +53,90 @@ func Load(id, rootDir string) (*Container, error) {
 		return nil, fmt.Errorf("read state file: %w", err)
 	}
 
-	var state *specs.State
-	if err := json.Unmarshal(s, &state); err != nil {
+	var persisted persistedState
+	if err := json.Unmarshal(s, &persisted); err != nil {
 		return nil, fmt.Errorf("unmarshal state: %w", err)
 	}
 
-	config, err := os.ReadFile(filepath.Join(state.Bundle, "config.json"))
-	if err != nil {
-		return nil, fmt.Errorf("read config file: %w", err)
-	}
-
-	var spec *specs.Spec
-	if err := json.Unmarshal(config, &spec); err != nil {
-		return nil, fmt.Errorf("unmarshal config: %w", err)
+	state := persisted.State
+	if state == nil {
+		return nil, errors.New("invalid state file")
 	}
 
 	c := &Container{
 		State:         state,
-		spec:          spec,
 		RootDir:       rootDir,
 		containerSock: containerSockPath(state.Bundle),
+		pidStartTime:  persisted.PIDStartTime,
+		rootfs:        persisted.RootFS,
+		created:       persisted.Created,
+		pidFile:       persisted.PIDFile,
+		ConsoleSocket: persisted.ConsoleSocket,
+		cgroupDriver:  persisted.CgroupDriver,
+		monitored:     persisted.Monitored,
+	}
+
+	if err := c.loadConfig(); err != nil {
+		return nil, err
 	}
 
 	return c, nil
 }
 
+// loadConfig loads the container spec from the config snapshot in the
+// container directory. Containers created before snapshots were taken don't
+// have one, so their config is read from the bundle and a snapshot is taken.
+func (c *Container) loadConfig() error {
+	config, err := os.ReadFile(c.configFilepath())
+	if err == nil {
+		if err := json.Unmarshal(config, &c.spec); err != nil {
+			return fmt.Errorf("unmarshal config: %w", err)
+		}
+
+		return nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	slog.Debug("config snapshot not found, read config from bundle", "container_id", c.State.ID, "bundle", c.State.Bundle)
+
+	config, err = os.ReadFile(filepath.Join(c.State.Bundle, configFilename))
+	if err != nil {
+		return fmt.Errorf(
+			"container %s has no config snapshot and its bundle config can't be read: %w",
+			c.State.ID, err,
+		)
+	}
+
+	if err := json.Unmarshal(config, &c.spec); err != nil {
+		return fmt.Errorf("unmarshal config: %w", err)
+	}
+
+	if err := c.saveConfig(); err != nil {
+		slog.Warn("failed to save config snapshot", "container_id", c.State.ID, "err", err)
+	}
+
+	return nil
+}
+
+// isContainerDir reports whether dir looks like a container directory, i.e.
+// contains a state or lock file.
+func isContainerDir(dir string) bool {
+	for _, name := range []string{"state.json", lockFilename} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
 // containerSockPath constructs the filepath to the socket used for container IPC.
-// Sockets are always placed in /run/anocir/<bundle-hash> so they're accessible by
-// the runtime and guaranteed to have a pathname within the 108 character limit.
+// Sockets are always placed in <runtime-dir>/<bundle-hash>, e.g.
+// /run/anocir/<bundle-hash>, so they're accessible by the runtime and
+// guaranteed to have a pathname within the 108 character limit.
 func containerSockPath(bundle string) string {
-	return filepath.Join("/run/anocir", ipc.ShortID(bundle), containerSockFilename)
+	return filepath.Join(platform.RuntimeDir(), ipc.ShortID(bundle), containerSockFilename)
 }
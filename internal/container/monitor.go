@@ -0,0 +1,412 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/nixpig/anocir/internal/container/ipc"
+	"github.com/nixpig/anocir/internal/platform"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// exitStatusFilename is the filename of the file the monitor process
+	// records the container process ExitStatus to.
+	exitStatusFilename = "exit.json"
+
+	// envMonitorSyncFD is the name of the environment variable used to pass
+	// the sync socket file descriptor to the monitor process.
+	envMonitorSyncFD = "_ANOCIR_MONITOR_SYNC_FD"
+
+	// memoryEventsTimeout is how long the monitor waits for the container
+	// cgroup to empty after the container process exits, to get the final
+	// OOM counts.
+	memoryEventsTimeout = time.Second
+)
+
+// ExitStatus records how the container process exited.
+type ExitStatus struct {
+	// ExitCode is the exit code of the container process. When the process was
+	// terminated by a signal it's 128 plus the signal number.
+	ExitCode int `json:"exitCode"`
+	// Signal is the name of the signal that terminated the container process,
+	// if any.
+	Signal string `json:"signal,omitempty"`
+	// OOMKilled is true when the OOM killer killed a process in the container
+	// cgroup.
+	OOMKilled bool `json:"oomKilled"`
+	// OOMEvents is the number of times the container cgroup reached its
+	// memory limit and the OOM killer was invoked.
+	OOMEvents uint64 `json:"oomEvents"`
+	// OOMKills is the number of processes in the container cgroup killed by
+	// the OOM killer.
+	OOMKills uint64 `json:"oomKills"`
+	// CreatedAt is the time the container was created.
+	CreatedAt time.Time `json:"createdAt"`
+	// FinishedAt is the time the container process exited.
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// MonitorOpts holds the options for the monitor process.
+type MonitorOpts struct {
+	PIDFile string
+	Debug   bool
+}
+
+// startMonitor starts the monitor process for the container and waits for it
+// to report whether the container was created. Stopping the monitor process
+// is added to the given rb.
+func (c *Container) startMonitor(rb *rollback) error {
+	args := []string{
+		"monitor",
+		"--root", c.RootDir,
+		"--log-format", c.LogFormat,
+		"--log", c.LogFile,
+	}
+
+	if c.ConsoleSocket != "" {
+		args = append(args, "--console-socket", c.ConsoleSocket)
+	}
+
+	if c.pidFile != "" {
+		args = append(args, "--pid-file", c.pidFile)
+	}
+
+	if c.debug {
+		args = append(args, "--debug")
+	}
+
+	args = append(args, c.State.ID)
+
+	syncSockParent, syncSockChild, err := ipc.NewSocketPair()
+	if err != nil {
+		return fmt.Errorf("new socketpair: %w", err)
+	}
+	defer func() {
+		if err := syncSockParent.Close(); err != nil {
+			slog.Warn("failed to close monitor socketpair parent", "container_id", c.State.ID, "err", err)
+		}
+	}()
+
+	cmd := exec.Command("/proc/self/exe", args...)
+
+	// The monitor is placed in its own session so that it outlives the runtime
+	// and isn't sent signals intended for it.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.ExtraFiles = []*os.File{syncSockChild}
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", envMonitorSyncFD, 3))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		syncSockChild.Close()
+		return fmt.Errorf("start monitor process: %w", err)
+	}
+
+	slog.Debug("monitor process started", "container_id", c.State.ID, "pid", cmd.Process.Pid)
+
+	c.monitor = cmd
+
+	rb.add("stop monitor process", func() error {
+		// The monitor rolls back the container process before reporting an
+		// error, so it's safe to kill.
+		if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+
+		_ = cmd.Wait()
+		c.monitor = nil
+
+		return nil
+	})
+
+	if err := syncSockChild.Close(); err != nil {
+		slog.Warn("failed to close monitor socketpair child", "container_id", c.State.ID, "err", err)
+	}
+
+	conn, err := net.FileConn(syncSockParent)
+	if err != nil {
+		return fmt.Errorf("monitor sock file conn: %w", err)
+	}
+	defer func() {
+		if err := conn.Close(); err != nil {
+			slog.Warn("failed to close monitor socket connection", "container_id", c.State.ID, "err", err)
+		}
+	}()
+
+	msg, err := ipc.ReceiveMessage(conn)
+	if err != nil {
+		var ipcErr *ipc.Error
+		if errors.As(err, &ipcErr) {
+			return ipcErr
+		}
+
+		return fmt.Errorf("monitor exited before container was created: %w", err)
+	}
+
+	if msg != ipc.MsgReady {
+		return fmt.Errorf(
+			"expected MsgReady ('%b') but got '%b'",
+			ipc.MsgReady,
+			msg,
+		)
+	}
+
+	return nil
+}
+
+// Monitor is the entry point for the monitor process. It forks the container
+// process, reports the result back to the runtime, then waits for the
+// container process to exit and records its ExitStatus.
+func (c *Container) Monitor(opts *MonitorOpts) error {
+	c.pidFile = opts.PIDFile
+	c.debug = opts.Debug
+
+	syncSockFD := os.Getenv(envMonitorSyncFD)
+	if syncSockFD == "" {
+		return errors.New("missing monitor sock fd")
+	}
+
+	syncSockFDVal, err := strconv.Atoi(syncSockFD)
+	if err != nil {
+		return errors.New("invalid monitor sock fd: " + syncSockFD)
+	}
+
+	syncSockFile := os.NewFile(uintptr(syncSockFDVal), "monitor_sock_child")
+
+	conn, err := net.FileConn(syncSockFile)
+	if err != nil {
+		return fmt.Errorf("monitor sock file conn: %w", err)
+	}
+
+	// FileConn dups the fd, so close the inherited one to prevent it leaking
+	// into the container process.
+	if err := syncSockFile.Close(); err != nil {
+		slog.Warn("failed to close monitor sock file", "container_id", c.State.ID, "err", err)
+	}
+
+	// Orphaned processes from containers that don't have their own PID
+	// namespace are reparented to the monitor rather than the host init.
+	if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+		slog.Warn("failed to set monitor as child subreaper", "container_id", c.State.ID, "err", err)
+	}
+
+	if err := c.forkReexec(); err != nil {
+		if sendErr := ipc.SendError(conn, "create", err); sendErr != nil {
+			slog.Warn("failed to send monitor error message", "container_id", c.State.ID, "err", sendErr)
+		}
+		conn.Close()
+
+		return err
+	}
+
+	createdAt := time.Now()
+	pid := c.State.Pid
+	pidStartTime := c.pidStartTime
+
+	// The systemd drivers remove the container scope once it's empty, which
+	// can happen before the container process is reaped, so the OOM counts
+	// are watched while the cgroup is populated rather than read afterwards.
+	memoryEvents := c.watchMemoryEvents()
+
+	slog.Debug("send monitor ready message", "container_id", c.State.ID)
+	if err := ipc.SendMessage(conn, ipc.MsgReady); err != nil {
+		return fmt.Errorf("failed to send monitor ready message: %w", err)
+	}
+
+	if err := conn.Close(); err != nil {
+		slog.Warn("failed to close monitor sock conn", "container_id", c.State.ID, "err", err)
+	}
+
+	// The container process holds its own copies of stdio, so release ours to
+	// avoid holding pipes open after the container exits.
+	if err := redirectStdioToDevNull(); err != nil {
+		slog.Warn("failed to redirect monitor stdio", "container_id", c.State.ID, "err", err)
+	}
+
+	ws, err := waitForProcess(pid)
+	if err != nil {
+		return fmt.Errorf("wait for container process: %w", err)
+	}
+
+	status := newExitStatus(ws)
+	status.CreatedAt = createdAt
+
+	var oom platform.MemoryEvents
+	select {
+	case oom = <-memoryEvents:
+	case <-time.After(memoryEventsTimeout):
+		// Processes left behind by the container process keep the cgroup
+		// populated, so it can still be read.
+		oom, err = platform.GetCgroupMemoryEvents(c.cgroup())
+		if err != nil {
+			slog.Debug("failed to get cgroup memory events", "container_id", c.State.ID, "err", err)
+		}
+	}
+
+	status.OOMEvents = oom.OOM
+	status.OOMKills = oom.OOMKill
+	status.OOMKilled = oom.OOMKill > 0
+
+	slog.Debug("container process exited", "container_id", c.State.ID, "pid", pid, "exit_code", status.ExitCode, "signal", status.Signal)
+
+	// The container may have been deleted, and its ID reused, while the
+	// monitor was waiting.
+	if err := c.reloadState(); err != nil ||
+		c.State.Pid != pid ||
+		c.pidStartTime != pidStartTime {
+		slog.Debug("container no longer exists, discard exit status", "container_id", c.State.ID, "pid", pid)
+		return nil
+	}
+
+	return c.saveExitStatus(status)
+}
+
+// watchMemoryEvents watches the memory events of the container cgroup and
+// sends the last counts to the returned channel once the cgroup is empty.
+func (c *Container) watchMemoryEvents() <-chan platform.MemoryEvents {
+	result := make(chan platform.MemoryEvents, 1)
+
+	events, errs, err := platform.WatchCgroupMemoryEvents(c.cgroup())
+	if err != nil {
+		// Without a cgroup to watch there are no OOM counts to record.
+		slog.Debug("failed to watch cgroup memory events", "container_id", c.State.ID, "err", err)
+		close(result)
+		return result
+	}
+
+	initial, err := platform.GetCgroupMemoryEvents(c.cgroup())
+	if err != nil {
+		slog.Debug("failed to get cgroup memory events", "container_id", c.State.ID, "err", err)
+	}
+
+	go func() {
+		last, err := lastMemoryEvents(initial, events, errs)
+		if err != nil {
+			slog.Debug("failed to watch cgroup memory events", "container_id", c.State.ID, "err", err)
+		}
+
+		result <- last
+	}()
+
+	return result
+}
+
+// lastMemoryEvents returns the last counts sent to events, or initial if
+// none were, once events is closed.
+func lastMemoryEvents(
+	initial platform.MemoryEvents,
+	events <-chan platform.MemoryEvents,
+	errs <-chan error,
+) (platform.MemoryEvents, error) {
+	last := initial
+	for e := range events {
+		last = e
+	}
+
+	return last, <-errs
+}
+
+// GetExitStatus returns the ExitStatus recorded by the monitor process, or
+// nil if the container process hasn't exited.
+func (c *Container) GetExitStatus() (*ExitStatus, error) {
+	data, err := os.ReadFile(c.exitStatusFilepath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("read exit status file: %w", err)
+	}
+
+	var status ExitStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("unmarshal exit status: %w", err)
+	}
+
+	return &status, nil
+}
+
+func (c *Container) saveExitStatus(status *ExitStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("serialise exit status: %w", err)
+	}
+
+	if err := platform.AtomicWriteFile(
+		c.exitStatusFilepath(),
+		data,
+		0o644,
+	); err != nil {
+		return fmt.Errorf("write exit status: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Container) exitStatusFilepath() string {
+	return filepath.Join(c.containerDir(), exitStatusFilename)
+}
+
+// waitForProcess reaps children of the monitor until the process with the
+// given pid exits, and returns its wait status.
+func waitForProcess(pid int) (unix.WaitStatus, error) {
+	for {
+		var ws unix.WaitStatus
+
+		wpid, err := unix.Wait4(-1, &ws, 0, nil)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		if wpid == pid {
+			return ws, nil
+		}
+	}
+}
+
+// newExitStatus creates an ExitStatus from the given wait status.
+func newExitStatus(ws unix.WaitStatus) *ExitStatus {
+	status := &ExitStatus{FinishedAt: time.Now()}
+
+	if ws.Signaled() {
+		status.ExitCode = 128 + int(ws.Signal())
+		status.Signal = unix.SignalName(ws.Signal())
+	} else {
+		status.ExitCode = ws.ExitStatus()
+	}
+
+	return status
+}
+
+// redirectStdioToDevNull replaces the stdio file descriptors of the current
+// process with /dev/null.
+func redirectStdioToDevNull() error {
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", os.DevNull, err)
+	}
+	defer devNull.Close()
+
+	for fd := range 3 {
+		if err := unix.Dup2(int(devNull.Fd()), fd); err != nil {
+			return fmt.Errorf("dup2 fd %d: %w", fd, err)
+		}
+	}
+
+	return nil
+}
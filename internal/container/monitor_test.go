@@ -0,0 +1,134 @@
+package container
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestNewExitStatus(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		script   string
+		exitCode int
+		signal   string
+	}{
+		"process exits successfully": {
+			script:   "exit 0",
+			exitCode: 0,
+		},
+		"process exits with error": {
+			script:   "exit 3",
+			exitCode: 3,
+		},
+		"process terminated by signal": {
+			script:   "kill -9 $$",
+			exitCode: 137,
+			signal:   "SIGKILL",
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := exec.Command("sh", "-c", data.script)
+			cmd.Run()
+
+			ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+			require.True(t, ok)
+
+			status := newExitStatus(unix.WaitStatus(ws))
+			assert.Equal(t, data.exitCode, status.ExitCode)
+			assert.Equal(t, data.signal, status.Signal)
+			assert.False(t, status.FinishedAt.IsZero())
+		})
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	t.Parallel()
+
+	c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+
+	status, err := c.GetExitStatus()
+	assert.NoError(t, err)
+	assert.Nil(t, status, "exit status should be nil before the container exits")
+
+	require.NoError(t, c.saveExitStatus(&ExitStatus{
+		ExitCode:  137,
+		Signal:    "SIGKILL",
+		OOMKilled: true,
+		OOMEvents: 2,
+		OOMKills:  1,
+	}))
+
+	status, err = c.GetExitStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, 137, status.ExitCode)
+	assert.Equal(t, "SIGKILL", status.Signal)
+	assert.True(t, status.OOMKilled)
+	assert.Equal(t, uint64(2), status.OOMEvents)
+	assert.Equal(t, uint64(1), status.OOMKills)
+}
+
+func TestLastMemoryEvents(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		initial platform.MemoryEvents
+		events  []platform.MemoryEvents
+		err     error
+		want    platform.MemoryEvents
+	}{
+		"test no events returns initial counts": {
+			initial: platform.MemoryEvents{OOM: 1},
+			want:    platform.MemoryEvents{OOM: 1},
+		},
+		"test returns last event counts": {
+			initial: platform.MemoryEvents{},
+			events: []platform.MemoryEvents{
+				{OOM: 1},
+				{OOM: 2, OOMKill: 1},
+			},
+			want: platform.MemoryEvents{OOM: 2, OOMKill: 1},
+		},
+		"test returns watch error": {
+			initial: platform.MemoryEvents{},
+			events:  []platform.MemoryEvents{{OOM: 1, OOMKill: 1}},
+			err:     errors.New("watch failed"),
+			want:    platform.MemoryEvents{OOM: 1, OOMKill: 1},
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			events := make(chan platform.MemoryEvents, len(data.events))
+			errs := make(chan error, 1)
+
+			for _, e := range data.events {
+				events <- e
+			}
+			close(events)
+
+			if data.err != nil {
+				errs <- data.err
+			}
+			close(errs)
+
+			got, err := lastMemoryEvents(data.initial, events, errs)
+			assert.Equal(t, data.err, err)
+			assert.Equal(t, data.want, got)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// ReclaimOpts holds the options for reclaiming the memory of a container.
+type ReclaimOpts struct {
+	// Amount is the bytes of memory to reclaim. It's ignored if Percent is
+	// set.
+	Amount uint64
+	// Percent is the percentage of the current memory usage to reclaim.
+	Percent float64
+	// Swappiness, if set, overrides the swappiness of the reclaim, from 0,
+	// i.e. only page cache, to platform.MaxSwappiness.
+	Swappiness *uint64
+	// Freeze freezes the container while memory is reclaimed, so its
+	// processes don't fault pages back in.
+	Freeze bool
+}
+
+// ReclaimReport is the outcome of reclaiming the memory of a container.
+type ReclaimReport struct {
+	ID string `json:"id"`
+	// Requested is the bytes of memory asked to be reclaimed.
+	Requested uint64 `json:"requested"`
+	// Reclaimed is how much the memory usage went down by.
+	Reclaimed uint64 `json:"reclaimed"`
+	// Complete is whether the kernel reclaimed all of Requested.
+	Complete bool                  `json:"complete"`
+	Before   *platform.MemoryUsage `json:"before"`
+	After    *platform.MemoryUsage `json:"after"`
+}
+
+// Reclaim proactively reclaims memory from the container cgroup with the
+// given opts, without killing any processes.
+func (c *Container) Reclaim(opts *ReclaimOpts) (*ReclaimReport, error) {
+	if err := c.Lock(); err != nil {
+		return nil, fmt.Errorf("acquire container lock: %w", err)
+	}
+	defer c.Unlock()
+
+	if err := c.reloadState(); err != nil {
+		return nil, fmt.Errorf("reload container state: %w", err)
+	}
+
+	if c.State.Status == specs.StateStopped {
+		return nil, errors.New("container is stopped")
+	}
+
+	before, err := platform.GetCgroupMemoryUsage(c.cgroup())
+	if err != nil {
+		return nil, fmt.Errorf("get memory usage: %w", err)
+	}
+
+	report := &ReclaimReport{
+		ID:        c.State.ID,
+		Requested: reclaimAmount(opts, before.Current),
+		Complete:  true,
+		Before:    before,
+	}
+
+	if report.Requested > 0 {
+		if report.Complete, err = c.reclaim(
+			report.Requested,
+			opts,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	if report.After, err = platform.GetCgroupMemoryUsage(c.cgroup()); err != nil {
+		return nil, fmt.Errorf("get memory usage: %w", err)
+	}
+
+	if report.After.Current < before.Current {
+		report.Reclaimed = before.Current - report.After.Current
+	}
+
+	return report, nil
+}
+
+// reclaim reclaims amount bytes of memory from the container cgroup, frozen
+// if opts says so and the container isn't already paused.
+func (c *Container) reclaim(amount uint64, opts *ReclaimOpts) (bool, error) {
+	freeze := opts.Freeze && c.State.Status != PausedState
+
+	if freeze {
+		if err := platform.FreezeCgroup(c.cgroup(), DefaultFreezeTimeout); err != nil {
+			return false, fmt.Errorf("freeze cgroup: %w", err)
+		}
+	}
+
+	complete, err := platform.ReclaimCgroupMemory(
+		c.cgroup(),
+		amount,
+		opts.Swappiness,
+	)
+	if err != nil {
+		err = fmt.Errorf("reclaim memory: %w", err)
+	}
+
+	if freeze {
+		if thawErr := platform.ThawCgroup(c.cgroup()); thawErr != nil {
+			err = errors.Join(err, fmt.Errorf("thaw cgroup: %w", thawErr))
+		}
+	}
+
+	return complete, err
+}
+
+// reclaimAmount returns the bytes of memory to reclaim for the given opts and
+// current memory usage.
+func reclaimAmount(opts *ReclaimOpts, current uint64) uint64 {
+	if opts.Percent > 0 {
+		return uint64(float64(current) * opts.Percent / 100)
+	}
+
+	return opts.Amount
+}
@@ -0,0 +1,30 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReclaimAmount(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		opts    *ReclaimOpts
+		current uint64
+		want    uint64
+	}{
+		"test amount":          {opts: &ReclaimOpts{Amount: 4096}, current: 1 << 20, want: 4096},
+		"test percentage":      {opts: &ReclaimOpts{Percent: 25}, current: 1 << 20, want: 1 << 18},
+		"test percentage wins": {opts: &ReclaimOpts{Amount: 4096, Percent: 50}, current: 1 << 20, want: 1 << 19},
+		"test no usage":        {opts: &ReclaimOpts{Percent: 50}, current: 0, want: 0},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, data.want, reclaimAmount(data.opts, data.current))
+		})
+	}
+}
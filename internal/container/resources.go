@@ -0,0 +1,304 @@
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// resourcesFilename is the filename of the effective resources of the
+// container, stored in the container directory once they're updated.
+const resourcesFilename = "resources.json"
+
+const (
+	// OOMGroupAnnotation is the annotation that, set to "true", makes the OOM
+	// killer kill all the processes of the container together, rather than
+	// only the largest, unless the unified key is set in the spec.
+	OOMGroupAnnotation = "org.anocir.oom-group"
+
+	// oomGroupUnifiedKey is the cgroup interface file that enables group OOM
+	// kills.
+	oomGroupUnifiedKey = "memory.oom.group"
+)
+
+// UpdateResources merges the given, possibly partial, resources into the
+// effective resources of the container, applies them to the container cgroup
+// and records them. It returns the keys of the unified settings that were
+// applied.
+func (c *Container) UpdateResources(update *specs.LinuxResources) ([]string, error) {
+	if err := c.Lock(); err != nil {
+		return nil, fmt.Errorf("acquire container lock: %w", err)
+	}
+	defer c.Unlock()
+
+	if err := c.reloadState(); err != nil {
+		return nil, fmt.Errorf("reload container state: %w", err)
+	}
+
+	if c.State.Status == specs.StateStopped {
+		return nil, errors.New("container is stopped")
+	}
+
+	current, err := c.GetResources()
+	if err != nil {
+		return nil, fmt.Errorf("get effective resources: %w", err)
+	}
+
+	resources, err := mergeResources(current, update)
+	if err != nil {
+		return nil, fmt.Errorf("merge resources: %w", err)
+	}
+
+	// The merged resources are validated, since the update may only be
+	// inconsistent with the current resources, e.g. a memory limit above the
+	// current swap limit.
+	if err := ValidateResources(resources); err != nil {
+		return nil, fmt.Errorf("invalid resources: %w", err)
+	}
+
+	// The merged resources are applied, rather than only the update, since
+	// some values depend on others, e.g. the cgroup swap limit is calculated
+	// from the memory limit.
+	unified, err := platform.UpdateCgroup(
+		c.cgroup(),
+		c.cgroupResources(resources),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("update cgroup: %w", err)
+	}
+
+	data, err := json.Marshal(resources)
+	if err != nil {
+		return nil, fmt.Errorf("serialise resources: %w", err)
+	}
+
+	if err := platform.AtomicWriteFile(
+		c.resourcesFilepath(),
+		data,
+		0o644,
+	); err != nil {
+		return nil, fmt.Errorf("write resources: %w", err)
+	}
+
+	return unified, nil
+}
+
+// GetResources returns the effective resources of the container, i.e. those
+// last applied by UpdateResources, or those in the spec if they've never been
+// updated.
+func (c *Container) GetResources() (*specs.LinuxResources, error) {
+	data, err := os.ReadFile(c.resourcesFilepath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("read resources file: %w", err)
+		}
+
+		if c.spec == nil || c.spec.Linux == nil || c.spec.Linux.Resources == nil {
+			return &specs.LinuxResources{}, nil
+		}
+
+		return c.spec.Linux.Resources, nil
+	}
+
+	var resources specs.LinuxResources
+	if err := json.Unmarshal(data, &resources); err != nil {
+		return nil, fmt.Errorf("unmarshal resources: %w", err)
+	}
+
+	return &resources, nil
+}
+
+// CheckResources compares the container cgroup with the effective resources
+// of the container, returning any values that don't match.
+func (c *Container) CheckResources() ([]platform.ResourceDrift, error) {
+	resources, err := c.GetResources()
+	if err != nil {
+		return nil, fmt.Errorf("get effective resources: %w", err)
+	}
+
+	drift, err := platform.CheckCgroupResources(c.cgroup(), resources)
+	if err != nil {
+		return nil, fmt.Errorf("check cgroup resources: %w", err)
+	}
+
+	return drift, nil
+}
+
+// DeviceFilter returns the device filter of the container cgroup, for the
+// effective device rules of the container.
+func (c *Container) DeviceFilter() (*platform.DeviceFilter, error) {
+	resources, err := c.GetResources()
+	if err != nil {
+		return nil, fmt.Errorf("get effective resources: %w", err)
+	}
+
+	filter, err := platform.GetCgroupDeviceFilter(
+		c.cgroup(),
+		c.deviceRules(resources.Devices),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get cgroup device filter: %w", err)
+	}
+
+	return filter, nil
+}
+
+// cgroupResources returns the given resources as they're applied to the
+// container cgroup, i.e. with the effective device rules and group OOM kills
+// if OOMGroupAnnotation is set.
+func (c *Container) cgroupResources(
+	resources *specs.LinuxResources,
+) *specs.LinuxResources {
+	oomGroup := c.oomGroup()
+
+	if resources == nil && !oomGroup {
+		return nil
+	}
+
+	var applied specs.LinuxResources
+	if resources != nil {
+		applied = *resources
+	}
+
+	applied.Devices = c.deviceRules(applied.Devices)
+
+	if _, ok := applied.Unified[oomGroupUnifiedKey]; oomGroup && !ok {
+		applied.Unified = maps.Clone(applied.Unified)
+		if applied.Unified == nil {
+			applied.Unified = map[string]string{}
+		}
+
+		applied.Unified[oomGroupUnifiedKey] = "1"
+	}
+
+	return &applied
+}
+
+// oomGroup returns whether OOMGroupAnnotation is set on the container.
+func (c *Container) oomGroup() bool {
+	return c.spec != nil && c.spec.Annotations[OOMGroupAnnotation] == "true"
+}
+
+// deviceRules returns the effective device rules for the given rules, which
+// also allow the devices of the container.
+func (c *Container) deviceRules(
+	rules []specs.LinuxDeviceCgroup,
+) []specs.LinuxDeviceCgroup {
+	var devices []specs.LinuxDevice
+	if c.spec != nil && c.spec.Linux != nil {
+		devices = c.spec.Linux.Devices
+	}
+
+	return platform.DeviceRules(rules, devices)
+}
+
+// ValidateResources checks the given resources are consistent.
+func ValidateResources(resources *specs.LinuxResources) error {
+	if m := resources.Memory; m != nil {
+		for name, v := range map[string]*int64{
+			"memory":             m.Limit,
+			"memory reservation": m.Reservation,
+			"memory swap":        m.Swap,
+		} {
+			if v != nil && *v < -1 {
+				return fmt.Errorf("%s must be -1 or a positive size: %d", name, *v)
+			}
+		}
+
+		if isLimited(m.Limit) && isLimited(m.Swap) && *m.Swap < *m.Limit {
+			return fmt.Errorf("memory swap (%d) must not be less than memory (%d)", *m.Swap, *m.Limit)
+		}
+
+		if isLimited(m.Limit) && isLimited(m.Reservation) && *m.Reservation > *m.Limit {
+			return fmt.Errorf("memory reservation (%d) must not be more than memory (%d)", *m.Reservation, *m.Limit)
+		}
+	}
+
+	if c := resources.CPU; c != nil {
+		if c.Quota != nil && *c.Quota != -1 && *c.Quota < 1000 {
+			return fmt.Errorf("cpu quota must be -1 or at least 1000: %d", *c.Quota)
+		}
+
+		if c.Period != nil && (*c.Period < 1000 || *c.Period > 1000000) {
+			return fmt.Errorf("cpu period must be from 1000 to 1000000: %d", *c.Period)
+		}
+	}
+
+	if b := resources.BlockIO; b != nil && b.Weight != nil {
+		if *b.Weight != 0 && (*b.Weight < 10 || *b.Weight > 1000) {
+			return fmt.Errorf("blkio weight must be from 10 to 1000: %d", *b.Weight)
+		}
+	}
+
+	return nil
+}
+
+// isLimited reports whether v is set to a limit, i.e. not nil or -1.
+func isLimited(v *int64) bool {
+	return v != nil && *v != -1
+}
+
+func (c *Container) resourcesFilepath() string {
+	return filepath.Join(c.containerDir(), resourcesFilename)
+}
+
+// mergeResources merges update into base, returning the result. Fields set in
+// update replace those in base, objects are merged recursively, and lists are
+// replaced as a whole.
+func mergeResources(
+	base *specs.LinuxResources,
+	update *specs.LinuxResources,
+) (*specs.LinuxResources, error) {
+	var merged map[string]any
+	if err := remarshal(base, &merged); err != nil {
+		return nil, fmt.Errorf("convert base resources: %w", err)
+	}
+
+	var changes map[string]any
+	if err := remarshal(update, &changes); err != nil {
+		return nil, fmt.Errorf("convert updated resources: %w", err)
+	}
+
+	if merged == nil {
+		merged = make(map[string]any)
+	}
+
+	mergeObjects(merged, changes)
+
+	var resources specs.LinuxResources
+	if err := remarshal(merged, &resources); err != nil {
+		return nil, fmt.Errorf("convert merged resources: %w", err)
+	}
+
+	return &resources, nil
+}
+
+func mergeObjects(dst, src map[string]any) {
+	for k, v := range src {
+		srcObject, srcIsObject := v.(map[string]any)
+		dstObject, dstIsObject := dst[k].(map[string]any)
+
+		if srcIsObject && dstIsObject {
+			mergeObjects(dstObject, srcObject)
+			continue
+		}
+
+		dst[k] = v
+	}
+}
+
+// remarshal converts from to the type of to, via JSON.
+func remarshal(from, to any) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, to)
+}
@@ -0,0 +1,258 @@
+package container
+
+import (
+	"os"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeResources(t *testing.T) {
+	t.Parallel()
+
+	ptr := func(v int64) *int64 { return &v }
+	shares := uint64(1024)
+
+	scenarios := map[string]struct {
+		base   *specs.LinuxResources
+		update *specs.LinuxResources
+		want   *specs.LinuxResources
+	}{
+		"test empty base": {
+			base:   &specs.LinuxResources{},
+			update: &specs.LinuxResources{Pids: &specs.LinuxPids{Limit: ptr(100)}},
+			want:   &specs.LinuxResources{Pids: &specs.LinuxPids{Limit: ptr(100)}},
+		},
+		"test partial update keeps other values": {
+			base: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: ptr(1024), Swap: ptr(2048)},
+				CPU:    &specs.LinuxCPU{Shares: &shares, Cpus: "0-1"},
+			},
+			update: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: ptr(512)},
+			},
+			want: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: ptr(512), Swap: ptr(2048)},
+				CPU:    &specs.LinuxCPU{Shares: &shares, Cpus: "0-1"},
+			},
+		},
+		"test lists are replaced": {
+			base: &specs.LinuxResources{
+				HugepageLimits: []specs.LinuxHugepageLimit{
+					{Pagesize: "2MB", Limit: 1024},
+					{Pagesize: "1GB", Limit: 0},
+				},
+			},
+			update: &specs.LinuxResources{
+				HugepageLimits: []specs.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 2048}},
+			},
+			want: &specs.LinuxResources{
+				HugepageLimits: []specs.LinuxHugepageLimit{{Pagesize: "2MB", Limit: 2048}},
+			},
+		},
+		"test unified is merged": {
+			base: &specs.LinuxResources{
+				Unified: map[string]string{"memory.high": "1024", "pids.max": "10"},
+			},
+			update: &specs.LinuxResources{
+				Unified: map[string]string{"memory.high": "2048"},
+			},
+			want: &specs.LinuxResources{
+				Unified: map[string]string{"memory.high": "2048", "pids.max": "10"},
+			},
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			merged, err := mergeResources(data.base, data.update)
+			require.NoError(t, err)
+
+			assert.Equal(t, data.want, merged)
+		})
+	}
+}
+
+func TestGetResources(t *testing.T) {
+	t.Parallel()
+
+	limit := int64(1024)
+
+	c := newTestContainer(t, &specs.Spec{
+		Linux: &specs.Linux{
+			Resources: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: &limit},
+			},
+		},
+	})
+	require.NoError(t, os.MkdirAll(c.containerDir(), 0o755))
+
+	resources, err := c.GetResources()
+	require.NoError(t, err)
+	assert.Equal(t, c.spec.Linux.Resources, resources)
+
+	require.NoError(t, os.WriteFile(
+		c.resourcesFilepath(),
+		[]byte(`{"memory":{"limit":2048}}`),
+		0o644,
+	))
+
+	resources, err = c.GetResources()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2048), *resources.Memory.Limit)
+}
+
+func TestCgroupResources(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		annotations map[string]string
+		resources   *specs.LinuxResources
+		expected    *specs.LinuxResources
+	}{
+		"test no resources": {
+			resources: nil,
+			expected:  nil,
+		},
+		"test oom group annotation without resources": {
+			annotations: map[string]string{OOMGroupAnnotation: "true"},
+			resources:   nil,
+			expected: &specs.LinuxResources{
+				Unified: map[string]string{"memory.oom.group": "1"},
+			},
+		},
+		"test oom group annotation with unified": {
+			annotations: map[string]string{OOMGroupAnnotation: "true"},
+			resources: &specs.LinuxResources{
+				Unified: map[string]string{"memory.high": "1024"},
+			},
+			expected: &specs.LinuxResources{
+				Unified: map[string]string{
+					"memory.high":      "1024",
+					"memory.oom.group": "1",
+				},
+			},
+		},
+		"test unified key takes precedence over oom group annotation": {
+			annotations: map[string]string{OOMGroupAnnotation: "true"},
+			resources: &specs.LinuxResources{
+				Unified: map[string]string{"memory.oom.group": "0"},
+			},
+			expected: &specs.LinuxResources{
+				Unified: map[string]string{"memory.oom.group": "0"},
+			},
+		},
+		"test oom group annotation not true": {
+			annotations: map[string]string{OOMGroupAnnotation: "false"},
+			resources:   &specs.LinuxResources{},
+			expected:    &specs.LinuxResources{},
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestContainer(t, &specs.Spec{
+				Annotations: data.annotations,
+				Linux:       &specs.Linux{},
+			})
+
+			var unified map[string]string
+			if data.resources != nil {
+				unified = data.resources.Unified
+			}
+
+			assert.Equal(t, data.expected, c.cgroupResources(data.resources))
+			if data.resources != nil {
+				assert.Equal(t, unified, data.resources.Unified, "given resources should not be modified")
+			}
+		})
+	}
+}
+
+func TestValidateResources(t *testing.T) {
+	t.Parallel()
+
+	ptr := func(v int64) *int64 { return &v }
+	weight := func(v uint16) *uint16 { return &v }
+
+	scenarios := map[string]struct {
+		resources *specs.LinuxResources
+		wantErr   bool
+	}{
+		"test empty": {
+			resources: &specs.LinuxResources{},
+		},
+		"test valid memory": {
+			resources: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: ptr(1024), Reservation: ptr(512), Swap: ptr(2048)},
+			},
+		},
+		"test unlimited swap": {
+			resources: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: ptr(1024), Swap: ptr(-1)},
+			},
+		},
+		"test swap less than memory": {
+			resources: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: ptr(1024), Swap: ptr(512)},
+			},
+			wantErr: true,
+		},
+		"test reservation more than memory": {
+			resources: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: ptr(1024), Reservation: ptr(2048)},
+			},
+			wantErr: true,
+		},
+		"test invalid cpu quota": {
+			resources: &specs.LinuxResources{CPU: &specs.LinuxCPU{Quota: ptr(10)}},
+			wantErr:   true,
+		},
+		"test invalid blkio weight": {
+			resources: &specs.LinuxResources{
+				BlockIO: &specs.LinuxBlockIO{Weight: weight(5)},
+			},
+			wantErr: true,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateResources(data.resources)
+			if data.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestUpdateResourcesValidatesMerged(t *testing.T) {
+	t.Parallel()
+
+	limit := int64(1 << 30)
+	updated := int64(2 << 30)
+
+	c := newTestContainer(t, &specs.Spec{
+		Linux: &specs.Linux{
+			Resources: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: &limit, Swap: &limit},
+			},
+		},
+	})
+
+	_, err := c.UpdateResources(&specs.LinuxResources{
+		Memory: &specs.LinuxMemory{Limit: &updated},
+	})
+	assert.ErrorContains(t, err, "memory swap")
+}
@@ -0,0 +1,50 @@
+package container
+
+import "log/slog"
+
+// rollback records how to release each resource acquired by a multi-step
+// operation, so that if the operation fails partway, everything acquired so
+// far can be released in reverse order.
+type rollback struct {
+	containerID string
+	steps       []rollbackStep
+	committed   bool
+}
+
+type rollbackStep struct {
+	name string
+	undo func() error
+}
+
+func newRollback(containerID string) *rollback {
+	return &rollback{containerID: containerID}
+}
+
+// add records the undo function for a resource that was just acquired.
+func (r *rollback) add(name string, undo func() error) {
+	r.steps = append(r.steps, rollbackStep{name, undo})
+}
+
+// commit marks the operation as successful, so run does nothing.
+func (r *rollback) commit() {
+	r.committed = true
+}
+
+// run releases the recorded resources in reverse order, unless the operation
+// was committed. It's intended to be deferred. Failures are logged, since the
+// original error is the one returned to the caller.
+func (r *rollback) run() {
+	if r.committed {
+		return
+	}
+
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		step := r.steps[i]
+
+		slog.Debug("rollback", "container_id", r.containerID, "step", step.name)
+
+		if err := step.undo(); err != nil {
+			slog.Warn("failed to rollback", "container_id", r.containerID, "step", step.name, "err", err)
+		}
+	}
+}
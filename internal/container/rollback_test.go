@@ -0,0 +1,52 @@
+package container
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollback(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		commit bool
+		want   []string
+	}{
+		"test rollback runs steps in reverse": {
+			commit: false,
+			want:   []string{"third", "second", "first"},
+		},
+		"test committed rollback runs no steps": {
+			commit: true,
+			want:   nil,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+
+			rb := newRollback("test-container")
+			for _, name := range []string{"first", "second", "third"} {
+				rb.add(name, func() error {
+					got = append(got, name)
+
+					// A failed step doesn't prevent the remaining steps.
+					return errors.New("failed")
+				})
+			}
+
+			if data.commit {
+				rb.commit()
+			}
+
+			rb.run()
+
+			assert.Equal(t, data.want, got)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+
+	"github.com/nixpig/anocir/internal/container/ipc"
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// sendSeccompListener returns a platform.ProcessSecurity SeccompListener for
+// a container process, which sends the seccomp notify fd to the runtime on
+// conn and waits for it to be passed on to the seccomp agent.
+func sendSeccompListener(conn net.Conn) func(*os.File) error {
+	return func(notifyFD *os.File) error {
+		if err := ipc.SendFile(conn, ipc.MsgSeccompListener, notifyFD); err != nil {
+			return fmt.Errorf("send seccomp listener message: %w", err)
+		}
+
+		msg, err := ipc.ReceiveMessage(conn)
+		if err != nil {
+			return fmt.Errorf("receive seccomp listener sent message: %w", err)
+		}
+
+		if msg != ipc.MsgSeccompListenerSent {
+			return fmt.Errorf(
+				"expecting MsgSeccompListenerSent ('%b') but received '%b'",
+				ipc.MsgSeccompListenerSent,
+				msg,
+			)
+		}
+
+		return nil
+	}
+}
+
+// receiveMessage reads the next message from the container process with the
+// given pid on conn. Any seccomp notify fd it sends first is passed on to the
+// seccomp agent at the listenerPath of seccomp, with the given state.
+func receiveMessage(
+	conn net.Conn,
+	seccomp *specs.LinuxSeccomp,
+	state *specs.State,
+	pid int,
+) (byte, error) {
+	for {
+		msg, notifyFD, err := ipc.ReceiveMessageFile(conn)
+		if err != nil {
+			return 0, err
+		}
+
+		if msg != ipc.MsgSeccompListener {
+			if notifyFD != nil {
+				notifyFD.Close()
+			}
+
+			return msg, nil
+		}
+
+		if err := forwardSeccompListener(seccomp, state, pid, notifyFD); err != nil {
+			// The container process fails rather than running without its
+			// seccomp agent.
+			if sendErr := ipc.SendError(conn, "send seccomp listener", err); sendErr != nil {
+				slog.Warn("failed to send seccomp listener error", "container_id", state.ID, "err", sendErr)
+			}
+
+			return 0, err
+		}
+
+		if err := ipc.SendMessage(conn, ipc.MsgSeccompListenerSent); err != nil {
+			return 0, fmt.Errorf("send seccomp listener sent message: %w", err)
+		}
+	}
+}
+
+// forwardSeccompListener sends the seccomp notify fd of the container process
+// with the given pid to the seccomp agent, and closes it.
+func forwardSeccompListener(
+	seccomp *specs.LinuxSeccomp,
+	state *specs.State,
+	pid int,
+	notifyFD *os.File,
+) error {
+	if notifyFD == nil {
+		return errors.New("seccomp listener message has no notify fd")
+	}
+	defer notifyFD.Close()
+
+	if seccomp == nil || seccomp.ListenerPath == "" {
+		return errors.New("no seccomp listenerPath for notify fd")
+	}
+
+	slog.Debug("send seccomp notify fd", "container_id", state.ID, "pid", pid, "listener_path", seccomp.ListenerPath)
+
+	return platform.SendSeccompListener(
+		seccomp.ListenerPath,
+		notifyFD,
+		&specs.ContainerProcessState{
+			Version:  specs.Version,
+			Fds:      []string{specs.SeccompFdName},
+			Pid:      pid,
+			Metadata: seccomp.ListenerMetadata,
+			State:    *state,
+		},
+	)
+}
@@ -0,0 +1,127 @@
+package container
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nixpig/anocir/internal/container/ipc"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiveMessageSeccompListener(t *testing.T) {
+	t.Parallel()
+
+	listenerPath := filepath.Join(t.TempDir(), "agent.sock")
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: listenerPath, Net: "unix"})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	runtimeFile, processFile, err := ipc.NewSocketPair()
+	require.NoError(t, err)
+
+	runtimeConn, err := net.FileConn(runtimeFile)
+	require.NoError(t, err)
+	defer runtimeConn.Close()
+
+	processConn, err := net.FileConn(processFile)
+	require.NoError(t, err)
+	defer processConn.Close()
+
+	notifyFD, err := os.CreateTemp(t.TempDir(), "notify")
+	require.NoError(t, err)
+	defer notifyFD.Close()
+
+	processErr := make(chan error, 1)
+	go func() {
+		if err := sendSeccompListener(processConn)(notifyFD); err != nil {
+			processErr <- err
+			return
+		}
+
+		processErr <- ipc.SendMessage(processConn, ipc.MsgExecReady)
+	}()
+
+	agentState := make(chan *specs.ContainerProcessState, 1)
+	go func() {
+		conn, err := listener.AcceptUnix()
+		if err != nil {
+			close(agentState)
+			return
+		}
+		defer conn.Close()
+
+		buf := make([]byte, 4096)
+		oob := make([]byte, 64)
+
+		n, _, _, _, err := conn.ReadMsgUnix(buf, oob)
+		if err != nil {
+			close(agentState)
+			return
+		}
+
+		var state specs.ContainerProcessState
+		if err := json.Unmarshal(buf[:n], &state); err != nil {
+			close(agentState)
+			return
+		}
+
+		agentState <- &state
+	}()
+
+	seccomp := &specs.LinuxSeccomp{
+		ListenerPath:     listenerPath,
+		ListenerMetadata: "metadata",
+	}
+	state := &specs.State{ID: "test-container", Status: specs.StateCreated}
+
+	msg, err := receiveMessage(runtimeConn, seccomp, state, 1234)
+	require.NoError(t, err)
+	assert.Equal(t, ipc.MsgExecReady, msg)
+	assert.NoError(t, <-processErr)
+
+	received := <-agentState
+	require.NotNil(t, received)
+	assert.Equal(t, []string{specs.SeccompFdName}, received.Fds)
+	assert.Equal(t, 1234, received.Pid)
+	assert.Equal(t, "metadata", received.Metadata)
+	assert.Equal(t, "test-container", received.State.ID)
+}
+
+func TestReceiveMessageSeccompListenerFailed(t *testing.T) {
+	t.Parallel()
+
+	runtimeFile, processFile, err := ipc.NewSocketPair()
+	require.NoError(t, err)
+
+	runtimeConn, err := net.FileConn(runtimeFile)
+	require.NoError(t, err)
+	defer runtimeConn.Close()
+
+	processConn, err := net.FileConn(processFile)
+	require.NoError(t, err)
+	defer processConn.Close()
+
+	notifyFD, err := os.CreateTemp(t.TempDir(), "notify")
+	require.NoError(t, err)
+	defer notifyFD.Close()
+
+	processErr := make(chan error, 1)
+	go func() {
+		processErr <- sendSeccompListener(processConn)(notifyFD)
+	}()
+
+	seccomp := &specs.LinuxSeccomp{
+		ListenerPath: filepath.Join(t.TempDir(), "missing.sock"),
+	}
+	state := &specs.State{ID: "test-container"}
+
+	_, err = receiveMessage(runtimeConn, seccomp, state, 1234)
+	assert.Error(t, err)
+	assert.Error(t, <-processErr, "container process should fail without its seccomp agent")
+}
@@ -2,6 +2,7 @@ package container
 
 import (
 	"fmt"
+	"net"
 	"slices"
 
 	"github.com/nixpig/anocir/internal/platform"
@@ -10,8 +11,8 @@ import (
 )
 
 // setupPostPivot performs configuration of the container environment after
-// pivot_root.
-func (c *Container) setupPostPivot() error {
+// pivot_root. Any seccomp notify fd is sent to the runtime on conn.
+func (c *Container) setupPostPivot(conn net.Conn) error {
 	if len(c.spec.Linux.Sysctl) > 0 {
 		if err := platform.SetSysctl(c.spec.Linux.Sysctl); err != nil {
 			return fmt.Errorf("set sysctl: %w", err)
@@ -86,6 +87,7 @@ func (c *Container) setupPostPivot() error {
 		NoNewPrivs:      c.spec.Process.NoNewPrivileges,
 		AppArmorProfile: c.spec.Process.ApparmorProfile,
 		ProcessLabel:    c.spec.Process.SelinuxLabel,
+		SeccompListener: sendSeccompListener(conn),
 	}); err != nil {
 		return fmt.Errorf("apply process security: %w", err)
 	}
@@ -0,0 +1,209 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+	"unsafe"
+
+	"github.com/nixpig/anocir/internal/platform"
+	"golang.org/x/sys/unix"
+)
+
+// exitStatusGracePeriod is how long to wait for the monitor process to record
+// the ExitStatus after the container process has exited.
+const exitStatusGracePeriod = 2 * time.Second
+
+var (
+	// ErrWaitTimeout is returned when the container doesn't exit within the
+	// given timeout.
+	ErrWaitTimeout = errors.New("timed out waiting for container to exit")
+
+	// ErrExitStatusNotRecorded is returned when the container process has
+	// exited but no ExitStatus was recorded, e.g. because the monitor process
+	// was killed or the container isn't monitored.
+	ErrExitStatusNotRecorded = errors.New("container exited without recording exit status")
+)
+
+// Wait blocks until the container process exits and returns its ExitStatus.
+// It watches the container directory with inotify for the ExitStatus recorded
+// by the monitor process, and the container process with a pidfd, so it works
+// from any process with access to the container directory. A zero timeout
+// waits indefinitely.
+//
+// Only the monitor process records an ExitStatus, so for a container without
+// one it returns ErrExitStatusNotRecorded once the container process exits.
+func (c *Container) Wait(timeout time.Duration) (*ExitStatus, error) {
+	status, err := c.waitForExitStatus(timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reap the monitor process if it's a child of this process.
+	if c.monitor != nil {
+		if err := c.monitor.Wait(); err != nil {
+			slog.Debug("monitor process exited with error", "container_id", c.State.ID, "err", err)
+		}
+	}
+
+	return status, nil
+}
+
+func (c *Container) waitForExitStatus(timeout time.Duration) (*ExitStatus, error) {
+	inotifyFD, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("init inotify: %w", err)
+	}
+	defer unix.Close(inotifyFD)
+
+	if _, err := unix.InotifyAddWatch(
+		inotifyFD,
+		c.containerDir(),
+		unix.IN_CREATE|unix.IN_MOVED_TO|unix.IN_DELETE_SELF,
+	); err != nil {
+		return nil, fmt.Errorf("watch container directory: %w", err)
+	}
+
+	// Check for an existing exit status only once the watch is in place, so
+	// one recorded in the meantime isn't missed.
+	if status, err := c.GetExitStatus(); err != nil || status != nil {
+		return status, err
+	}
+
+	if err := c.reloadState(); err != nil {
+		return nil, fmt.Errorf("reload container state: %w", err)
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	// graceDeadline is set once the container process has exited, after which
+	// the exit status is expected to be recorded.
+	var graceDeadline time.Time
+
+	pidFD := -1
+	defer func() {
+		if pidFD >= 0 {
+			unix.Close(pidFD)
+		}
+	}()
+
+	if c.State.Pid > 0 {
+		pidFD, err = platform.OpenPidfd(c.State.Pid, c.pidStartTime)
+		if err != nil {
+			if !errors.Is(err, platform.ErrProcessNotFound) {
+				return nil, fmt.Errorf("open pidfd: %w", err)
+			}
+
+			if !c.monitored {
+				return nil, ErrExitStatusNotRecorded
+			}
+
+			pidFD = -1
+			graceDeadline = time.Now().Add(exitStatusGracePeriod)
+		}
+	}
+
+	buf := make([]byte, 4096)
+
+	for {
+		fds := []unix.PollFd{{Fd: int32(inotifyFD), Events: unix.POLLIN}}
+		if pidFD >= 0 {
+			fds = append(fds, unix.PollFd{Fd: int32(pidFD), Events: unix.POLLIN})
+		}
+
+		pollTimeout := -1
+		if d := earliest(deadline, graceDeadline); !d.IsZero() {
+			pollTimeout = max(int(time.Until(d).Milliseconds()), 0)
+		}
+
+		n, err := unix.Poll(fds, pollTimeout)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+
+			return nil, fmt.Errorf("poll: %w", err)
+		}
+
+		if n == 0 {
+			if !graceDeadline.IsZero() && !time.Now().Before(graceDeadline) {
+				return nil, ErrExitStatusNotRecorded
+			}
+
+			return nil, ErrWaitTimeout
+		}
+
+		if fds[0].Revents&unix.POLLIN != 0 {
+			deleted, err := readInotifyEvents(inotifyFD, buf)
+			if err != nil {
+				return nil, fmt.Errorf("read inotify events: %w", err)
+			}
+
+			if status, err := c.GetExitStatus(); err != nil || status != nil {
+				return status, err
+			}
+
+			if deleted {
+				return nil, fmt.Errorf("container %s was deleted", c.State.ID)
+			}
+		}
+
+		if pidFD >= 0 && fds[1].Revents != 0 {
+			if !c.monitored {
+				return nil, ErrExitStatusNotRecorded
+			}
+
+			slog.Debug("container process exited, wait for exit status", "container_id", c.State.ID, "pid", c.State.Pid)
+
+			// The monitor records the exit status shortly after the container
+			// process exits.
+			unix.Close(pidFD)
+			pidFD = -1
+			graceDeadline = time.Now().Add(exitStatusGracePeriod)
+		}
+	}
+}
+
+// readInotifyEvents drains the pending events from the given inotifyFD and
+// reports whether the watched directory was deleted.
+func readInotifyEvents(inotifyFD int, buf []byte) (bool, error) {
+	deleted := false
+
+	for {
+		n, err := unix.Read(inotifyFD, buf)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) {
+				return deleted, nil
+			}
+
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+
+			return deleted, err
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			if event.Mask&(unix.IN_DELETE_SELF|unix.IN_IGNORED) != 0 {
+				deleted = true
+			}
+
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+		}
+	}
+}
+
+// earliest returns the earliest of the given deadlines, where a zero deadline
+// means no deadline.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+
+	return a
+}
@@ -0,0 +1,105 @@
+package container
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWait(t *testing.T) {
+	t.Parallel()
+
+	c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		c.saveExitStatus(&ExitStatus{ExitCode: 42})
+	}()
+
+	status, err := c.Wait(5 * time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 42, status.ExitCode)
+}
+
+func TestWaitExitStatusAlreadyRecorded(t *testing.T) {
+	t.Parallel()
+
+	c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+
+	require.NoError(t, c.saveExitStatus(&ExitStatus{ExitCode: 1}))
+
+	status, err := c.Wait(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.ExitCode)
+}
+
+func TestWaitTimeout(t *testing.T) {
+	t.Parallel()
+
+	c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+
+	status, err := c.Wait(50 * time.Millisecond)
+	assert.ErrorIs(t, err, ErrWaitTimeout)
+	assert.Nil(t, status)
+}
+
+func TestWaitContainerDeleted(t *testing.T) {
+	t.Parallel()
+
+	c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.RemoveAll(c.containerDir())
+	}()
+
+	status, err := c.Wait(5 * time.Second)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrWaitTimeout)
+	assert.Nil(t, status)
+}
+
+func TestWaitExitStatusNotRecorded(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		monitored bool
+		minWait   time.Duration
+	}{
+		"test monitored container waits for exit status": {
+			monitored: true,
+			minWait:   exitStatusGracePeriod,
+		},
+		"test unmonitored container returns immediately": {
+			monitored: false,
+			minWait:   0,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestContainer(t, &specs.Spec{Linux: &specs.Linux{}})
+
+			// Use a PID that can't exist.
+			c.State.Pid = 1 << 30
+			c.monitored = data.monitored
+			require.NoError(t, c.Save())
+
+			start := time.Now()
+			status, err := c.Wait(0)
+			assert.ErrorIs(t, err, ErrExitStatusNotRecorded)
+			assert.Nil(t, status)
+			assert.GreaterOrEqual(t, time.Since(start), data.minWait)
+
+			if !data.monitored {
+				assert.Less(t, time.Since(start), exitStatusGracePeriod)
+			}
+		})
+	}
+}
@@ -63,19 +63,30 @@ func childExecCmd() *cobra.Command {
 				}
 			}
 
+			var seccompListenerSock *os.File
+			if listenerFD := os.Getenv(container.EnvSeccompListenerFD); listenerFD != "" {
+				listenerFDNum, err := strconv.Atoi(listenerFD)
+				if err != nil {
+					return fmt.Errorf("convert seccomp listener fd number: %w", err)
+				}
+
+				seccompListenerSock = os.NewFile(uintptr(listenerFDNum), "seccomp_listener")
+			}
+
 			if err := container.ChildExec(&container.ChildExecOpts{
-				Cwd:             cwd,
-				Args:            execArgs,
-				Env:             envs,
-				User:            user,
-				Capabilities:    &specs.LinuxCapabilities{Bounding: caps},
-				NoNewPrivs:      noNewPrivs,
-				TTY:             tty,
-				ContainerID:     containerID,
-				Seccomp:         seccomp,
-				AppArmorProfile: appArmorProfile,
-				ProcessLabel:    processLabel,
-				Cgroup:          cgroup,
+				Cwd:                 cwd,
+				Args:                execArgs,
+				Env:                 envs,
+				User:                user,
+				Capabilities:        &specs.LinuxCapabilities{Bounding: caps},
+				NoNewPrivs:          noNewPrivs,
+				TTY:                 tty,
+				ContainerID:         containerID,
+				Seccomp:             seccomp,
+				AppArmorProfile:     appArmorProfile,
+				ProcessLabel:        processLabel,
+				Cgroup:              cgroup,
+				SeccompListenerSock: seccompListenerSock,
 			}); err != nil {
 				return fmt.Errorf("fork/exec child: %w", err)
 			}
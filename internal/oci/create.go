@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"reflect"
 
 	"github.com/nixpig/anocir/internal/container"
 	"github.com/nixpig/anocir/internal/platform"
@@ -16,8 +19,21 @@ import (
 
 func createCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "create [flags] CONTAINER_ID",
-		Short:   "Create a container",
+		Use:   "create [flags] CONTAINER_ID",
+		Short: "Create a container",
+		Long: `Create a container.
+
+The container cgroup is created in the cgroup filesystem at the cgroupsPath,
+or /anocir/<id> if it's not set. With --systemd-cgroup, or a cgroupsPath in
+the systemd slice:prefix:name form, it's a systemd scope instead, by default
+system.slice/anocir-<id>.scope. Earlier versions always used a systemd scope.
+
+Unprivileged users always get a scope of their systemd user instance. Without
+one the container has no cgroup, and create fails if it has resource limits.
+
+The container process is a child of the caller, which is expected to reap it,
+e.g. a shim that's a child subreaper. With --monitor it's a child of a monitor
+process instead, which records its exit status for wait, state and inspect.`,
 		Example: `  anocir create busybox`,
 		Args:    cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -38,33 +54,24 @@ func createCmd() *cobra.Command {
 			debug, _ := cmd.Flags().GetBool("debug")
 			logFile, _ := cmd.Flags().GetString("log")
 			logFormat, _ := cmd.Flags().GetString("log-format")
-
-			if container.Exists(containerID, rootDir) {
-				return fmt.Errorf("container '%s' exists", containerID)
-			}
-
-			spec, err := getContainerSpec(bundle)
-			if err != nil {
-				return fmt.Errorf("failed to get container spec: %w", err)
-			}
-
-			if err := createContainerDirs(rootDir, containerID); err != nil {
-				return fmt.Errorf("failed to create container dirs: %w", err)
-			}
-
-			cntr, err := container.New(&container.Opts{
-				ID:            containerID,
-				Bundle:        bundle,
-				Spec:          spec,
-				ConsoleSocket: consoleSocket,
-				PIDFile:       pidFile,
-				RootDir:       rootDir,
-				LogFile:       logFile,
-				LogFormat:     logFormat,
-				Debug:         debug,
+			systemdCgroup, _ := cmd.Flags().GetBool("systemd-cgroup")
+			monitor, _ := cmd.Flags().GetBool("monitor")
+
+			cntr, err := createContainer(&createOpts{
+				containerID:   containerID,
+				bundle:        bundle,
+				pidFile:       pidFile,
+				rootDir:       rootDir,
+				consoleSocket: consoleSocket,
+				logFile:       logFile,
+				logFormat:     logFormat,
+				debug:         debug,
+				systemdCgroup: systemdCgroup,
+				monitor:       monitor,
+				stderr:        cmd.ErrOrStderr(),
 			})
 			if err != nil {
-				return fmt.Errorf("failed to create container: %w", err)
+				return err
 			}
 
 			if err := cntr.Init(); err != nil {
@@ -79,10 +86,133 @@ func createCmd() *cobra.Command {
 	cmd.Flags().StringP("bundle", "b", cwd, "path of bundle directory")
 	cmd.Flags().String("console-socket", "", "console socket path")
 	cmd.Flags().String("pid-file", "", "file to write container PID to")
+	cmd.Flags().Bool("monitor", false, "fork the container process from a monitor process that records its exit status")
 
 	return cmd
 }
 
+// createOpts holds the options shared by the commands that create a new
+// container.
+type createOpts struct {
+	containerID   string
+	bundle        string
+	pidFile       string
+	rootDir       string
+	consoleSocket string
+	logFile       string
+	logFormat     string
+	debug         bool
+	systemdCgroup bool
+	monitor       bool
+	// stderr is where warnings about the created container are printed.
+	stderr io.Writer
+}
+
+// createContainer loads the spec from the bundle, creates the container
+// directories and constructs a new Container from the given opts.
+func createContainer(opts *createOpts) (*container.Container, error) {
+	if container.Exists(opts.containerID, opts.rootDir) {
+		return nil, fmt.Errorf("container '%s' exists", opts.containerID)
+	}
+
+	spec, err := getContainerSpec(opts.bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container spec: %w", err)
+	}
+
+	var cgroupsPath string
+	var resources *specs.LinuxResources
+	if spec.Linux != nil {
+		cgroupsPath = spec.Linux.CgroupsPath
+		resources = spec.Linux.Resources
+	}
+
+	cgroupDriver := getCgroupDriver(
+		opts.systemdCgroup,
+		cgroupsPath,
+		os.Geteuid() != 0,
+		platform.HasSystemdUserInstance,
+	)
+	if cgroupDriver == platform.NoCgroupDriver {
+		if hasResourceLimits(resources) {
+			return nil, errors.New("failed to create container: resource limits are set but there's no systemd user instance to create a cgroup with")
+		}
+
+		slog.Warn("no systemd user instance to create a cgroup with, container won't have one", "container_id", opts.containerID)
+		fmt.Fprintln(opts.stderr, "Warning: no systemd user instance to create a cgroup with, container won't have one")
+	}
+
+	if err := createContainerDirs(opts.rootDir, opts.containerID); err != nil {
+		return nil, fmt.Errorf("failed to create container dirs: %w", err)
+	}
+
+	cntr, err := container.New(&container.Opts{
+		ID:            opts.containerID,
+		Bundle:        opts.bundle,
+		Spec:          spec,
+		ConsoleSocket: opts.consoleSocket,
+		PIDFile:       opts.pidFile,
+		RootDir:       opts.rootDir,
+		LogFile:       opts.logFile,
+		LogFormat:     opts.logFormat,
+		Debug:         opts.debug,
+		CgroupDriver:  cgroupDriver,
+		Monitor:       opts.monitor,
+	})
+	if err != nil {
+		if err := os.RemoveAll(filepath.Join(opts.rootDir, opts.containerID)); err != nil {
+			slog.Warn("failed to remove container dir", "container_id", opts.containerID, "err", err)
+		}
+
+		return nil, fmt.Errorf("failed to create container: %w", err)
+	}
+
+	return cntr, nil
+}
+
+// getCgroupDriver returns the cgroup driver selected by the --systemd-cgroup
+// flag, or by a cgroupsPath in the systemd slice:prefix:name form, which the
+// cgroupfs driver can't use. Unprivileged users can only manage cgroups in the
+// subtree delegated to their systemd user instance, so it's always used for
+// them, or no cgroup at all if hasSystemdUser reports it's not available.
+func getCgroupDriver(
+	systemdCgroup bool,
+	cgroupsPath string,
+	unprivileged bool,
+	hasSystemdUser func() bool,
+) platform.CgroupDriver {
+	if unprivileged {
+		if !hasSystemdUser() {
+			return platform.NoCgroupDriver
+		}
+
+		return platform.SystemdUserDriver
+	}
+
+	if !systemdCgroup && !platform.IsSystemdCgroupsPath(cgroupsPath) {
+		return platform.CgroupfsDriver
+	}
+
+	return platform.SystemdDriver
+}
+
+// hasResourceLimits reports whether the given resources limit the container,
+// other than by device rules, which can't be enforced without a cgroup either
+// but are set in almost every spec.
+func hasResourceLimits(r *specs.LinuxResources) bool {
+	if r == nil {
+		return false
+	}
+
+	return (r.Memory != nil && *r.Memory != specs.LinuxMemory{}) ||
+		(r.CPU != nil && *r.CPU != specs.LinuxCPU{}) ||
+		(r.Pids != nil && r.Pids.Limit != nil && *r.Pids.Limit > 0) ||
+		(r.BlockIO != nil && !reflect.DeepEqual(*r.BlockIO, specs.LinuxBlockIO{})) ||
+		len(r.HugepageLimits) > 0 ||
+		len(r.Rdma) > 0 ||
+		len(r.Unified) > 0
+}
+
 func getContainerSpec(path string) (*specs.Spec, error) {
 	bundlePath, err := filepath.Abs(path)
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/nixpig/anocir/internal/platform"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -38,3 +39,100 @@ func TestCreateContainerDirs(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, info)
 }
+
+func TestGetCgroupDriver(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		systemdCgroup  bool
+		cgroupsPath    string
+		unprivileged   bool
+		hasSystemdUser bool
+		want           platform.CgroupDriver
+	}{
+		"test default driver": {
+			want: platform.CgroupfsDriver,
+		},
+		"test default driver with cgroupfs path": {
+			cgroupsPath: "/mygroup/ctr1",
+			want:        platform.CgroupfsDriver,
+		},
+		"test default driver with systemd path": {
+			cgroupsPath: "system.slice:anocir:ctr1",
+			want:        platform.SystemdDriver,
+		},
+		"test systemd cgroup flag": {
+			systemdCgroup: true,
+			want:          platform.SystemdDriver,
+		},
+		"test unprivileged": {
+			unprivileged:   true,
+			hasSystemdUser: true,
+			want:           platform.SystemdUserDriver,
+		},
+		"test unprivileged with systemd cgroup flag": {
+			systemdCgroup:  true,
+			unprivileged:   true,
+			hasSystemdUser: true,
+			want:           platform.SystemdUserDriver,
+		},
+		"test unprivileged without systemd user instance": {
+			unprivileged: true,
+			want:         platform.NoCgroupDriver,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			got := getCgroupDriver(
+				data.systemdCgroup,
+				data.cgroupsPath,
+				data.unprivileged,
+				func() bool { return data.hasSystemdUser },
+			)
+			assert.Equal(t, data.want, got)
+		})
+	}
+}
+
+func TestHasResourceLimits(t *testing.T) {
+	t.Parallel()
+
+	limit := int64(1 << 30)
+	unlimitedPids := int64(0)
+
+	scenarios := map[string]struct {
+		resources *specs.LinuxResources
+		want      bool
+	}{
+		"test nil":          {resources: nil},
+		"test empty":        {resources: &specs.LinuxResources{}},
+		"test empty memory": {resources: &specs.LinuxResources{Memory: &specs.LinuxMemory{}}},
+		"test devices only": {
+			resources: &specs.LinuxResources{
+				Devices: []specs.LinuxDeviceCgroup{{Allow: false, Access: "rwm"}},
+			},
+		},
+		"test unlimited pids": {
+			resources: &specs.LinuxResources{Pids: &specs.LinuxPids{Limit: &unlimitedPids}},
+		},
+		"test memory limit": {
+			resources: &specs.LinuxResources{Memory: &specs.LinuxMemory{Limit: &limit}},
+			want:      true,
+		},
+		"test unified": {
+			resources: &specs.LinuxResources{Unified: map[string]string{"memory.high": "1G"}},
+			want:      true,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, data.want, hasResourceLimits(data.resources))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nixpig/anocir/internal/container"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/spf13/cobra"
+)
+
+func eventsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "events [flags] CONTAINER_ID",
+		Short: "Display container events and resource usage statistics",
+		Long: `Display container events and resource usage statistics.
+
+Events are printed as JSON, one per line, in the same format as runc events.
+By default, the resource usage statistics are printed every interval, and oom
+and oom_kill events as they happen, until the container stops. With --stats,
+the statistics are printed once.`,
+		Example: "  anocir events --stats busybox\n  anocir events --interval 1s busybox",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			containerID := args[0]
+
+			rootDir, _ := cmd.Flags().GetString("root")
+			stats, _ := cmd.Flags().GetBool("stats")
+			interval, _ := cmd.Flags().GetDuration("interval")
+
+			if interval <= 0 {
+				return errors.New("interval must be greater than 0")
+			}
+
+			cntr, err := container.Load(containerID, rootDir)
+			if err != nil {
+				return fmt.Errorf("failed to load container: %w", err)
+			}
+
+			state, err := cntr.GetState()
+			if err != nil {
+				return fmt.Errorf("failed to get container state: %w", err)
+			}
+
+			if state.Status == specs.StateStopped {
+				return fmt.Errorf("cannot get events of a stopped container")
+			}
+
+			enc := json.NewEncoder(cmd.OutOrStdout())
+
+			if stats {
+				event, err := cntr.Stats()
+				if err != nil {
+					return fmt.Errorf("failed to get container stats: %w", err)
+				}
+
+				if err := enc.Encode(event); err != nil {
+					return fmt.Errorf("failed to print container stats: %w", err)
+				}
+
+				return nil
+			}
+
+			if err := cntr.Events(interval, func(event *container.Event) error {
+				return enc.Encode(event)
+			}); err != nil {
+				return fmt.Errorf("failed to get container events: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("stats", false, "print the resource usage statistics once and exit")
+	cmd.Flags().Duration("interval", 5*time.Second, "interval between resource usage statistics")
+
+	return cmd
+}
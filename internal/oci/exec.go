@@ -15,9 +15,18 @@ import (
 
 func execCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "exec [flags] CONTAINER_ID COMMAND [args]",
-		Short:   "Execute a command in a container",
-		Example: "  anocir exec busybox ps",
+		Use:   "exec [flags] CONTAINER_ID COMMAND [args]",
+		Short: "Execute a command in a container",
+		Long: `Execute a command in a container.
+
+If resource limits are given, by the resource flags or 'resources' in the
+--process JSON, the process is started in a sub-cgroup of the container cgroup
+with those limits. The sub-cgroup is at the --cgroup path, or exec-<pid> if
+it's not given, and is removed once the process exits. With --detach it's left
+until gc removes it. The container process is in the container cgroup, which
+can't limit memory or IO for its children, so only cpu, cpuset and pids limits
+are accepted.`,
+		Example: "  anocir exec busybox ps\n  anocir exec --pids-limit 20 --cpu-quota 20000 busybox backup.sh",
 		Args:    cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			containerID := args[0]
@@ -40,6 +49,9 @@ func execCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("failed to get state for container %s: %w", containerID, err)
 			}
+			if state.Status == specs.StateStopped {
+				return fmt.Errorf("cannot exec in a stopped container")
+			}
 			if state.Status == container.PausedState && !ignorePaused {
 				return fmt.Errorf("cannot exec in a paused container, use --ignore-paused to override")
 			}
@@ -63,6 +75,14 @@ func execCmd() *cobra.Command {
 				}
 			}
 
+			if err := parseExecResources(opts, cmd.Flags()); err != nil {
+				return fmt.Errorf("failed to parse resource limits: %w", err)
+			}
+
+			if opts.CgroupResources != nil {
+				opts.ContainerCgroup = cntr.GetCgroup()
+			}
+
 			if cntr.GetProcessEnv() != nil {
 				opts.Env = append(opts.Env, cntr.GetProcessEnv()...)
 			}
@@ -70,6 +90,7 @@ func execCmd() *cobra.Command {
 			spec := cntr.GetSpec()
 			if spec.Linux != nil && spec.Linux.Seccomp != nil {
 				opts.Seccomp = spec.Linux.Seccomp
+				opts.ContainerState = state
 			}
 
 			exitCode, err := container.Exec(state.Pid, opts)
@@ -92,7 +113,7 @@ func execCmd() *cobra.Command {
 	cmd.Flags().String("apparmor", "", "AppArmor profile for the process")
 	cmd.Flags().Bool("no-new-privs", false, "set no new privs")
 	cmd.Flags().StringArray("cap", []string{}, "set capabilities")
-	cmd.Flags().String("cgroup", "", "cgroup <path> to write PID to /sys/fs/cgroup/<container-cgroup>/<path>/cgroup.procs")
+	cmd.Flags().String("cgroup", "", "cgroup <path> to write PID to /sys/fs/cgroup/<container-cgroup>/<path>/cgroup.procs, created with any resource limits")
 	cmd.Flags().String("console-socket", "", "console socket path")
 	cmd.Flags().StringP("user", "u", "", "run command as user uid[:gid]")
 	cmd.Flags().String("pid-file", "", "file to write container PID to")
@@ -102,6 +123,8 @@ func execCmd() *cobra.Command {
 	cmd.Flags().Bool("ignore-paused", false, "allow exec in a paused container")
 	cmd.Flags().Int("preserve-fds", 0, "pass additional file descriptors to container")
 
+	addResourceFlags(cmd.Flags())
+
 	return cmd
 }
 
@@ -134,13 +157,20 @@ func parseUser(u string) (int, int, error) {
 	return uid, 0, nil
 }
 
+// execProcess is the --process JSON of exec, i.e. an OCI process with the
+// resource limits of its sub-cgroup.
+type execProcess struct {
+	specs.Process
+	Resources *specs.LinuxResources `json:"resources,omitempty"`
+}
+
 func parseProcessFile(opts *container.ExecOpts, process string) error {
 	data, err := os.ReadFile(process)
 	if err != nil {
 		return fmt.Errorf("read process file: %w", err)
 	}
 
-	var p specs.Process
+	var p execProcess
 	if err := json.Unmarshal(data, &p); err != nil {
 		return fmt.Errorf("parse process JSON: %w", err)
 	}
@@ -154,6 +184,7 @@ func parseProcessFile(opts *container.ExecOpts, process string) error {
 	opts.AppArmor = p.ApparmorProfile
 	opts.TTY = p.Terminal
 	opts.ProcessLabel = p.SelinuxLabel
+	opts.CgroupResources = p.Resources
 
 	if p.Capabilities != nil {
 		opts.Capabilities = p.Capabilities.Bounding
@@ -209,3 +240,29 @@ func parseProcessFlags(
 
 	return nil
 }
+
+// parseExecResources applies the resource flags that were set on top of any
+// resource limits from the process JSON, and validates them.
+func parseExecResources(opts *container.ExecOpts, flags *pflag.FlagSet) error {
+	resources := opts.CgroupResources
+	if resources == nil {
+		resources = &specs.LinuxResources{}
+	}
+
+	changed, err := parseResourceFlags(resources, flags)
+	if err != nil {
+		return err
+	}
+
+	if !changed && opts.CgroupResources == nil {
+		return nil
+	}
+
+	if err := container.ValidateResources(resources); err != nil {
+		return fmt.Errorf("invalid resources: %w", err)
+	}
+
+	opts.CgroupResources = resources
+
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/nixpig/anocir/internal/container"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -85,6 +86,8 @@ func TestParseUser(t *testing.T) {
 func TestParseProcessFile(t *testing.T) {
 	t.Parallel()
 
+	ptr := func(v int64) *int64 { return &v }
+
 	scenarios := map[string]struct {
 		path      string
 		testData  map[string]any
@@ -124,9 +127,15 @@ func TestParseProcessFile(t *testing.T) {
 				"noNewPrivileges": true,
 				"apparmorProfile": "default",
 				"selinuxLabel":    "system_u:system_r:container_t:s0",
+				"resources": map[string]any{
+					"pids": map[string]any{"limit": 10},
+				},
 			},
 			assertErr: assert.NoError,
 			wantOpts: &container.ExecOpts{
+				CgroupResources: &specs.LinuxResources{
+					Pids: &specs.LinuxPids{Limit: ptr(10)},
+				},
 				Cwd:            "/home/user",
 				Env:            []string{"PATH=/usr/bin", "TERM=xterm"},
 				Args:           []string{"/bin/sh", "-c", "echo hello"},
@@ -286,3 +295,72 @@ func TestParseProcessFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestParseExecResources(t *testing.T) {
+	t.Parallel()
+
+	ptr := func(v int64) *int64 { return &v }
+
+	scenarios := map[string]struct {
+		args      []string
+		resources *specs.LinuxResources
+		want      *specs.LinuxResources
+		wantErr   bool
+	}{
+		"test no limits": {
+			args: []string{},
+			want: nil,
+		},
+		"test flags": {
+			args: []string{"--memory", "256M", "--pids-limit", "20"},
+			want: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: ptr(256 << 20)},
+				Pids:   &specs.LinuxPids{Limit: ptr(20)},
+			},
+		},
+		"test flags on top of process JSON": {
+			args: []string{"--cpu-quota", "20000"},
+			resources: &specs.LinuxResources{
+				CPU:  &specs.LinuxCPU{Quota: ptr(50000)},
+				Pids: &specs.LinuxPids{Limit: ptr(20)},
+			},
+			want: &specs.LinuxResources{
+				CPU:  &specs.LinuxCPU{Quota: ptr(20000)},
+				Pids: &specs.LinuxPids{Limit: ptr(20)},
+			},
+		},
+		"test process JSON only": {
+			args: []string{},
+			resources: &specs.LinuxResources{
+				Pids: &specs.LinuxPids{Limit: ptr(20)},
+			},
+			want: &specs.LinuxResources{
+				Pids: &specs.LinuxPids{Limit: ptr(20)},
+			},
+		},
+		"test invalid": {
+			args:    []string{"--cpu-quota", "10"},
+			wantErr: true,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			fs := execCmd().Flags()
+			require.NoError(t, fs.Parse(data.args))
+
+			opts := &container.ExecOpts{CgroupResources: data.resources}
+
+			err := parseExecResources(opts, fs)
+			if data.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, data.want, opts.CgroupResources)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/nixpig/anocir/internal/container"
+	"github.com/spf13/cobra"
+)
+
+// gcReport is the report printed by the gc command with --dry-run.
+type gcReport struct {
+	Stale []*container.StaleResource `json:"stale"`
+}
+
+func gcCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc [flags]",
+		Short: "Find and remove stale container state, sockets and cgroups",
+		Long: `Find and remove stale container state, sockets and cgroups.
+
+Stale resources are directories of monitored containers whose process is gone
+without its exit status being recorded, socket directories no container refers
+to, container cgroups without a container, and exec-<pid> sub-cgroups left
+behind by detached exec processes that have exited. Only containers in --root are
+considered, so resources belonging to containers in another root directory
+are reported as stale.
+
+Container cgroups without a container that still have processes may belong to
+a container in another root directory, so they're skipped unless --force is
+given. With --force and --apply their processes are killed.
+
+By default the stale resources are reported. With --apply they're removed,
+running poststop hooks for stale containers where their spec is still
+available. With --dry-run the report is printed as JSON.`,
+		Example: "  anocir gc\n  anocir gc --dry-run\n  anocir gc --apply\n  anocir gc --apply --force",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rootDir, _ := cmd.Flags().GetString("root")
+			apply, _ := cmd.Flags().GetBool("apply")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			force, _ := cmd.Flags().GetBool("force")
+
+			if apply && dryRun {
+				return errors.New("--apply and --dry-run cannot be used together")
+			}
+
+			stale, err := container.FindStale(rootDir, force)
+			if err != nil {
+				return fmt.Errorf("failed to find stale resources: %w", err)
+			}
+
+			if dryRun {
+				output, err := json.Marshal(&gcReport{Stale: stale})
+				if err != nil {
+					return fmt.Errorf("failed to marshal report: %w", err)
+				}
+
+				if _, err := fmt.Fprintln(cmd.OutOrStdout(), string(output)); err != nil {
+					return fmt.Errorf("failed to print report: %w", err)
+				}
+
+				return nil
+			}
+
+			if apply {
+				container.RemoveStale(rootDir, stale)
+			}
+
+			if err := printStaleResources(cmd.OutOrStdout(), stale, apply); err != nil {
+				return fmt.Errorf("failed to print stale resources: %w", err)
+			}
+
+			for _, s := range stale {
+				if s.Error != "" {
+					return errors.New("failed to remove some stale resources")
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("apply", false, "remove the stale resources")
+	cmd.Flags().Bool("dry-run", false, "print a JSON report of the stale resources without removing them")
+	cmd.Flags().Bool("force", false, "include container cgroups without a container that still have processes")
+
+	return cmd
+}
+
+func printStaleResources(
+	out io.Writer,
+	stale []*container.StaleResource,
+	applied bool,
+) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	if applied {
+		fmt.Fprintf(w, "KIND\tID\tPATH\tREASON\tRESULT\t\n")
+	} else {
+		fmt.Fprintf(w, "KIND\tID\tPATH\tREASON\t\n")
+	}
+
+	for _, s := range stale {
+		id := s.ID
+		if id == "" {
+			id = "-"
+		}
+
+		if !applied {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", s.Kind, id, s.Path, s.Reason)
+			continue
+		}
+
+		result := "removed"
+		if !s.Removed {
+			result = "failed: " + s.Error
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", s.Kind, id, s.Path, s.Reason, result)
+	}
+
+	return w.Flush()
+}
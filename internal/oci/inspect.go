@@ -0,0 +1,92 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/nixpig/anocir/internal/container"
+	"github.com/spf13/cobra"
+)
+
+func inspectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect [flags] CONTAINER_ID",
+		Short: "Display the full runtime view of a container",
+		Long: `Display the full runtime view of a container.
+
+This combines the state, the effective spec, the rootfs, the cgroup, the
+namespaces and mounts of the container process, the console socket, pid file
+and socket paths, and timestamps.
+
+The output format is one of 'json' or a Go template, e.g. '{{.Cgroup.Path}}'.
+The template function 'json' marshals its argument, e.g. '{{json .Mounts}}'.`,
+		Example: "  anocir inspect busybox\n  anocir inspect --format '{{.State.Pid}}' busybox",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			containerID := args[0]
+
+			rootDir, _ := cmd.Flags().GetString("root")
+			format, _ := cmd.Flags().GetString("format")
+
+			cntr, err := container.Load(containerID, rootDir)
+			if err != nil {
+				return fmt.Errorf("failed to load container: %w", err)
+			}
+
+			inspection, err := cntr.Inspect()
+			if err != nil {
+				return fmt.Errorf("failed to inspect container: %w", err)
+			}
+
+			if err := formatInspectOutput(cmd.OutOrStdout(), format, inspection); err != nil {
+				return fmt.Errorf("failed to print container details: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("format", "f", "json", "output format (json | Go template)")
+
+	return cmd
+}
+
+func formatInspectOutput(
+	w io.Writer,
+	format string,
+	inspection *container.Inspection,
+) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(inspection, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal container details: %w", err)
+		}
+
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+
+	if !strings.Contains(format, "{{") {
+		return fmt.Errorf("invalid format: %s", format)
+	}
+
+	tmpl, err := template.New("inspect").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(format)
+	if err != nil {
+		return fmt.Errorf("parse format template: %w", err)
+	}
+
+	if err := tmpl.Execute(w, inspection); err != nil {
+		return fmt.Errorf("execute format template: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,70 @@
+package oci
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nixpig/anocir/internal/container"
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatInspectOutput(t *testing.T) {
+	t.Parallel()
+
+	inspection := &container.Inspection{
+		State: &specs.State{ID: "busybox", Pid: 1234, Status: specs.StateRunning},
+		Cgroup: &platform.CgroupInfo{
+			Slice: "system.slice",
+			Scope: "anocir-busybox.scope",
+			Path:  "/sys/fs/cgroup/system.slice/anocir-busybox.scope",
+		},
+		Namespaces: map[string]uint64{"net": 4026531993},
+		Socket:     "/run/anocir/0123456789abcdef/c.sock",
+		Dir:        "/run/anocir/busybox",
+	}
+
+	scenarios := map[string]struct {
+		format    string
+		output    string
+		assertErr assert.ErrorAssertionFunc
+	}{
+		"test template format": {
+			format:    "{{.State.Pid}} {{.Cgroup.Path}}",
+			output:    "1234 /sys/fs/cgroup/system.slice/anocir-busybox.scope\n",
+			assertErr: assert.NoError,
+		},
+		"test template json function": {
+			format:    "{{json .Namespaces}}",
+			output:    `{"net":4026531993}` + "\n",
+			assertErr: assert.NoError,
+		},
+		"test invalid format": {
+			format:    "yaml",
+			output:    "",
+			assertErr: assert.Error,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			var b bytes.Buffer
+			err := formatInspectOutput(&b, data.format, inspection)
+			data.assertErr(t, err)
+			assert.Equal(t, data.output, b.String())
+		})
+	}
+
+	t.Run("test json format", func(t *testing.T) {
+		t.Parallel()
+
+		var b bytes.Buffer
+		err := formatInspectOutput(&b, "json", inspection)
+		assert.NoError(t, err)
+		assert.Contains(t, b.String(), `"scope": "anocir-busybox.scope"`)
+		assert.Contains(t, b.String(), `"dir": "/run/anocir/busybox"`)
+	})
+}
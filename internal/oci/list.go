@@ -1,55 +1,101 @@
 package oci
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"text/tabwriter"
+	"text/template"
+	"time"
 
 	"github.com/nixpig/anocir/internal/container"
 	"github.com/spf13/cobra"
 )
 
 const (
-	idHeader    = "ID"
-	pidHeader   = "PID"
-	stateHeader = "STATE"
+	idHeader      = "ID"
+	pidHeader     = "PID"
+	stateHeader   = "STATE"
+	bundleHeader  = "BUNDLE"
+	rootfsHeader  = "ROOTFS"
+	createdHeader = "CREATED"
+	ownerHeader   = "OWNER"
 )
 
+// listEntry is a single container in the list command output. Containers that
+// can't be loaded are listed with the Error.
+type listEntry struct {
+	ID          string            `json:"id"`
+	PID         int               `json:"pid"`
+	Status      string            `json:"status"`
+	Bundle      string            `json:"bundle"`
+	Rootfs      string            `json:"rootfs"`
+	Created     time.Time         `json:"created,omitzero"`
+	Owner       string            `json:"owner"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// listFilter holds the filters applied to the list command output.
+type listFilter struct {
+	statuses    []string
+	annotations []string
+}
+
 func listCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "list [flags]",
-		Short:   "List all containers",
-		Example: "  anocir list",
+		Use:   "list [flags]",
+		Short: "List all containers",
+		Long: `List all containers.
+
+The output format is one of 'table', 'json' or a Go template, which is executed
+for each container, e.g. '{{.ID}} {{.Status}}'. Containers that can't be
+loaded are listed with their error rather than failing the command.`,
+		Example: "  anocir list\n  anocir list --format json --status running\n  anocir list --annotation io.kubernetes.cri.container-type=sandbox",
 		Args:    cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			rootDir, _ := cmd.Flags().GetString("root")
+			format, _ := cmd.Flags().GetString("format")
+			quiet, _ := cmd.Flags().GetBool("quiet")
+			statuses, _ := cmd.Flags().GetStringSlice("status")
+			annotations, _ := cmd.Flags().GetStringArray("annotation")
 
-			containerDirs, err := os.ReadDir(rootDir)
+			ids, err := container.List(rootDir)
 			if err != nil {
-				return fmt.Errorf("failed to read container directory: %w", err)
+				return fmt.Errorf("failed to list containers: %w", err)
 			}
 
-			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
-
-			fmt.Fprintf(w, "%s\t%s\t%s\t\n", idHeader, pidHeader, stateHeader)
-
-			for _, d := range containerDirs {
-				id := d.Name()
+			filter := &listFilter{statuses: statuses, annotations: annotations}
 
-				cntr, err := container.Load(id, rootDir)
-				if err != nil {
-					return fmt.Errorf("failed to load container %s: %w", id, err)
+			var entries []*listEntry
+			for _, id := range ids {
+				entry := getListEntry(id, rootDir)
+				if filter.matches(entry) {
+					entries = append(entries, entry)
 				}
+			}
+
+			if quiet {
+				for _, entry := range entries {
+					if entry.Error != "" {
+						fmt.Fprintf(cmd.ErrOrStderr(), "Warning: container %s: %s\n", entry.ID, entry.Error)
+					}
 
-				state, err := cntr.GetState()
-				if err != nil {
-					return fmt.Errorf("failed to get state for container %s: %w", id, err)
+					fmt.Fprintln(cmd.OutOrStdout(), entry.ID)
 				}
 
-				fmt.Fprintf(w, "%s\t%d\t%s\t\n", state.ID, state.Pid, state.Status)
+				return nil
 			}
 
-			if err := w.Flush(); err != nil {
+			if err := formatListOutput(cmd.OutOrStdout(), format, entries); err != nil {
 				return fmt.Errorf("failed to print container details: %w", err)
 			}
 
@@ -57,5 +103,149 @@ func listCmd() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().StringP("format", "f", "table", "output format (table | json | Go template)")
+	cmd.Flags().BoolP("quiet", "q", false, "only print container IDs")
+	cmd.Flags().StringSlice("status", nil, "only list containers with the given status, can be repeated")
+	cmd.Flags().StringArray("annotation", nil, "only list containers with the given annotation, as key or key=value, can be repeated")
+
 	return cmd
 }
+
+// getListEntry loads the container with the given id from rootDir and returns
+// its listEntry. Failures are recorded in the entry.
+func getListEntry(id, rootDir string) *listEntry {
+	entry := &listEntry{ID: id, Owner: containerOwner(filepath.Join(rootDir, id))}
+
+	cntr, err := container.Load(id, rootDir)
+	if err != nil {
+		entry.Error = fmt.Sprintf("failed to load container: %s", err)
+		return entry
+	}
+
+	state, err := cntr.GetState()
+	if err != nil {
+		entry.Error = fmt.Sprintf("failed to get state: %s", err)
+		return entry
+	}
+
+	entry.PID = state.Pid
+	entry.Status = string(state.Status)
+	entry.Bundle = state.Bundle
+	entry.Rootfs = cntr.GetRootFS()
+	entry.Created = cntr.GetCreated()
+	entry.Annotations = state.Annotations
+
+	return entry
+}
+
+// containerOwner returns the name of the owner of the given container dir, or
+// their UID if it can't be looked up.
+func containerOwner(dir string) string {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return ""
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+
+	uid := strconv.FormatUint(uint64(stat.Uid), 10)
+
+	u, err := user.LookupId(uid)
+	if err != nil {
+		return "#" + uid
+	}
+
+	return u.Username
+}
+
+// matches reports whether the given entry passes the filter. Entries with an
+// error can't be checked, so they always pass.
+func (f *listFilter) matches(entry *listEntry) bool {
+	if entry.Error != "" {
+		return true
+	}
+
+	if len(f.statuses) > 0 && !slices.Contains(f.statuses, entry.Status) {
+		return false
+	}
+
+	for _, annotation := range f.annotations {
+		key, value, hasValue := strings.Cut(annotation, "=")
+
+		v, ok := entry.Annotations[key]
+		if !ok || (hasValue && v != value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatListOutput(w io.Writer, format string, entries []*listEntry) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintf(
+			tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			idHeader, pidHeader, stateHeader, bundleHeader, rootfsHeader, createdHeader, ownerHeader,
+		)
+
+		for _, e := range entries {
+			if e.Error != "" {
+				fmt.Fprintf(tw, "%s\t-\terror: %s\t-\t-\t-\t%s\t\n", e.ID, e.Error, e.Owner)
+				continue
+			}
+
+			created := "-"
+			if !e.Created.IsZero() {
+				created = e.Created.Format(time.RFC3339Nano)
+			}
+
+			fmt.Fprintf(
+				tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t\n",
+				e.ID, e.PID, e.Status, e.Bundle, e.Rootfs, created, e.Owner,
+			)
+		}
+
+		return tw.Flush()
+
+	case "json":
+		if entries == nil {
+			entries = []*listEntry{}
+		}
+
+		data, err := json.Marshal(entries)
+		if err != nil {
+			return fmt.Errorf("marshal containers: %w", err)
+		}
+
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+
+	default:
+		if !strings.Contains(format, "{{") {
+			return fmt.Errorf("invalid format: %s", format)
+		}
+
+		tmpl, err := template.New("list").Parse(format)
+		if err != nil {
+			return fmt.Errorf("parse format template: %w", err)
+		}
+
+		var errs []error
+		for _, e := range entries {
+			if err := tmpl.Execute(w, e); err != nil {
+				errs = append(errs, fmt.Errorf("container %s: %w", e.ID, err))
+				continue
+			}
+
+			fmt.Fprintln(w)
+		}
+
+		return errors.Join(errs...)
+	}
+}
@@ -0,0 +1,142 @@
+package oci
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatListOutput(t *testing.T) {
+	t.Parallel()
+
+	entries := []*listEntry{
+		{
+			ID:      "busybox",
+			PID:     1234,
+			Status:  "running",
+			Bundle:  "/bundles/busybox",
+			Rootfs:  "/bundles/busybox/rootfs",
+			Created: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+			Owner:   "root",
+		},
+		{
+			ID:    "broken",
+			Owner: "root",
+			Error: "failed to load container: invalid state",
+		},
+	}
+
+	scenarios := map[string]struct {
+		format    string
+		entries   []*listEntry
+		output    string
+		assertErr assert.ErrorAssertionFunc
+	}{
+		"test table format": {
+			format:  "table",
+			entries: entries,
+			output: "ID       PID   STATE                                           BUNDLE            ROOTFS                   CREATED               OWNER  \n" +
+				"busybox  1234  running                                         /bundles/busybox  /bundles/busybox/rootfs  2025-01-02T03:04:05Z  root   \n" +
+				"broken   -     error: failed to load container: invalid state  -                 -                        -                     root   \n",
+			assertErr: assert.NoError,
+		},
+		"test json format": {
+			format:  "json",
+			entries: entries,
+			output: `[{"id":"busybox","pid":1234,"status":"running","bundle":"/bundles/busybox","rootfs":"/bundles/busybox/rootfs","created":"2025-01-02T03:04:05Z","owner":"root"},` +
+				`{"id":"broken","pid":0,"status":"","bundle":"","rootfs":"","owner":"root","error":"failed to load container: invalid state"}]` + "\n",
+			assertErr: assert.NoError,
+		},
+		"test json format without containers": {
+			format:    "json",
+			entries:   nil,
+			output:    "[]\n",
+			assertErr: assert.NoError,
+		},
+		"test template format": {
+			format:    "{{.ID}} {{.Status}}",
+			entries:   entries,
+			output:    "busybox running\nbroken \n",
+			assertErr: assert.NoError,
+		},
+		"test invalid format": {
+			format:    "yaml",
+			entries:   entries,
+			output:    "",
+			assertErr: assert.Error,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			var b bytes.Buffer
+			err := formatListOutput(&b, data.format, data.entries)
+			data.assertErr(t, err)
+			assert.Equal(t, data.output, b.String())
+		})
+	}
+}
+
+func TestListFilter(t *testing.T) {
+	t.Parallel()
+
+	entry := &listEntry{
+		ID:          "busybox",
+		Status:      "running",
+		Annotations: map[string]string{"role": "sandbox"},
+	}
+
+	scenarios := map[string]struct {
+		filter  *listFilter
+		entry   *listEntry
+		matches bool
+	}{
+		"test no filters": {
+			filter:  &listFilter{},
+			entry:   entry,
+			matches: true,
+		},
+		"test matching status": {
+			filter:  &listFilter{statuses: []string{"created", "running"}},
+			entry:   entry,
+			matches: true,
+		},
+		"test non-matching status": {
+			filter:  &listFilter{statuses: []string{"stopped"}},
+			entry:   entry,
+			matches: false,
+		},
+		"test matching annotation key": {
+			filter:  &listFilter{annotations: []string{"role"}},
+			entry:   entry,
+			matches: true,
+		},
+		"test matching annotation key and value": {
+			filter:  &listFilter{annotations: []string{"role=sandbox"}},
+			entry:   entry,
+			matches: true,
+		},
+		"test non-matching annotation value": {
+			filter:  &listFilter{annotations: []string{"role=container"}},
+			entry:   entry,
+			matches: false,
+		},
+		"test entry with error": {
+			filter:  &listFilter{statuses: []string{"stopped"}},
+			entry:   &listEntry{ID: "broken", Error: "failed"},
+			matches: true,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, data.matches, data.filter.matches(data.entry))
+		})
+	}
+}
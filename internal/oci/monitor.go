@@ -0,0 +1,50 @@
+package oci
+
+import (
+	"fmt"
+
+	"github.com/nixpig/anocir/internal/container"
+	"github.com/spf13/cobra"
+)
+
+func monitorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "monitor [flags] CONTAINER_ID",
+		Short:  internalUseMessage,
+		Args:   cobra.ExactArgs(1),
+		Hidden: true, // this command is only used internally
+		RunE: func(cmd *cobra.Command, args []string) error {
+			containerID := args[0]
+
+			rootDir, _ := cmd.Flags().GetString("root")
+			consoleSocket, _ := cmd.Flags().GetString("console-socket")
+			pidFile, _ := cmd.Flags().GetString("pid-file")
+			debug, _ := cmd.Flags().GetBool("debug")
+			logFile, _ := cmd.Flags().GetString("log")
+			logFormat, _ := cmd.Flags().GetString("log-format")
+
+			cntr, err := container.Load(containerID, rootDir)
+			if err != nil {
+				return fmt.Errorf("failed to load container: %w", err)
+			}
+
+			cntr.ConsoleSocket = consoleSocket
+			cntr.LogFile = logFile
+			cntr.LogFormat = logFormat
+
+			if err := cntr.Monitor(&container.MonitorOpts{
+				PIDFile: pidFile,
+				Debug:   debug,
+			}); err != nil {
+				return fmt.Errorf("failed to monitor container: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("console-socket", "", "console socket path")
+	cmd.Flags().String("pid-file", "", "file to write container PID to")
+
+	return cmd
+}
@@ -2,33 +2,93 @@ package oci
 
 import (
 	"testing"
+	"time"
 
+	"github.com/nixpig/anocir/internal/platform"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestFormatProcessOutput(t *testing.T) {
 	t.Parallel()
 
+	processes := []*platform.ProcessInfo{
+		{
+			PID:     69,
+			NSPID:   2,
+			PPID:    23,
+			User:    "root",
+			State:   "S",
+			CPUTime: 61 * time.Second,
+			RSS:     2048,
+			Command: "sleep 100",
+		},
+		{
+			PID:     23,
+			NSPID:   1,
+			PPID:    10,
+			User:    "root",
+			State:   "S",
+			CPUTime: time.Second,
+			RSS:     4096,
+			Command: "sh",
+		},
+		{
+			PID:     12345,
+			NSPID:   3,
+			PPID:    69,
+			User:    "nobody",
+			State:   "R",
+			RSS:     1024,
+			Command: "yes",
+		},
+	}
+
 	scenarios := map[string]struct {
-		processes []int
+		processes []*platform.ProcessInfo
 		format    string
+		tree      bool
 		output    string
 		assertErr assert.ErrorAssertionFunc
 	}{
 		"format processes as table": {
-			processes: []int{23, 69, 12345},
+			processes: processes,
 			format:    "table",
-			output:    "23\n69\n12345\n",
+			output: "USER    PID    NSPID  PPID  STAT  STIME  TIME      RSS  COMMAND\n" +
+				"root    23     1      10    S     -      00:00:01  4    sh\n" +
+				"root    69     2      23    S     -      00:01:01  2    sleep 100\n" +
+				"nobody  12345  3      69    R     -      00:00:00  1    yes\n",
+			assertErr: assert.NoError,
+		},
+		"format processes as tree": {
+			processes: processes,
+			format:    "table",
+			tree:      true,
+			output: "USER    PID    NSPID  PPID  STAT  STIME  TIME      RSS  COMMAND\n" +
+				"root    23     1      10    S     -      00:00:01  4    sh\n" +
+				"root    69     2      23    S     -      00:01:01  2     \\_ sleep 100\n" +
+				"nobody  12345  3      69    R     -      00:00:00  1       \\_ yes\n",
 			assertErr: assert.NoError,
 		},
 		"format processes as json": {
-			processes: []int{23, 69, 12345},
+			processes: processes,
 			format:    "json",
-			output:    "[23,69,12345]",
+			output:    "[69,23,12345]",
+			assertErr: assert.NoError,
+		},
+		"format no processes as json": {
+			processes: nil,
+			format:    "json",
+			output:    "[]",
+			assertErr: assert.NoError,
+		},
+		"format processes as detailed json": {
+			processes: processes[2:],
+			format:    "json-detailed",
+			output:    `[{"pid":12345,"nspid":3,"ppid":69,"uid":0,"user":"nobody","state":"R","startTime":"0001-01-01T00:00:00Z","cpuTime":0,"rss":1024,"command":"yes"}]`,
 			assertErr: assert.NoError,
 		},
 		"invalid format type": {
-			processes: []int{23, 69, 12345},
+			processes: processes,
 			format:    "invalid",
 			output:    "",
 			assertErr: assert.Error,
@@ -39,9 +99,48 @@ func TestFormatProcessOutput(t *testing.T) {
 		t.Run(scenario, func(t *testing.T) {
 			t.Parallel()
 
-			output, err := formatProcessesOutput(data.format, data.processes)
+			output, err := formatProcessesOutput(data.format, data.processes, data.tree)
 			data.assertErr(t, err)
 			assert.Equal(t, data.output, output)
 		})
 	}
 }
+
+func TestFilterPsOutput(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		output    string
+		pids      []int
+		filtered  string
+		assertErr assert.ErrorAssertionFunc
+	}{
+		"filter to container processes": {
+			output: "UID          PID    PPID  C STIME TTY          TIME CMD\n" +
+				"root           1       0  0 10:00 ?        00:00:01 /sbin/init\n" +
+				"root         123       1  0 10:01 ?        00:00:00 sh\n" +
+				"root         456     123  0 10:01 ?        00:00:00 sleep 100\n",
+			pids: []int{123, 456},
+			filtered: "UID          PID    PPID  C STIME TTY          TIME CMD\n" +
+				"root         123       1  0 10:01 ?        00:00:00 sh\n" +
+				"root         456     123  0 10:01 ?        00:00:00 sleep 100\n",
+			assertErr: assert.NoError,
+		},
+		"missing pid field": {
+			output:    "COMMAND\nsh\n",
+			pids:      []int{123},
+			filtered:  "",
+			assertErr: assert.Error,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			filtered, err := filterPsOutput(data.output, data.pids)
+			data.assertErr(t, err)
+			assert.Equal(t, data.filtered, filtered)
+		})
+	}
+}
@@ -9,21 +9,31 @@ import (
 
 func pauseCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "pause [flags] CONTAINER_ID",
-		Short:   "Pause a running container",
-		Example: "  anocir pause busybox",
+		Use:   "pause [flags] CONTAINER_ID",
+		Short: "Pause a running container",
+		Long: `Pause a running container.
+
+All the processes of the container, including those of exec sub-cgroups, are
+frozen. If they aren't all frozen within --timeout, e.g. because a process is
+stuck in uninterruptible sleep, the container is thawed and left running.`,
+		Example: "  anocir pause busybox\n  anocir pause --timeout 30s busybox",
 		Args:    cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			containerID := args[0]
 
 			rootDir, _ := cmd.Flags().GetString("root")
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+
+			if timeout <= 0 {
+				return fmt.Errorf("timeout must be greater than 0")
+			}
 
 			cntr, err := container.Load(containerID, rootDir)
 			if err != nil {
 				return fmt.Errorf("failed to load container: %w", err)
 			}
 
-			if err := cntr.Pause(); err != nil {
+			if err := cntr.Pause(timeout); err != nil {
 				return fmt.Errorf("failed to pause container: %w", err)
 			}
 
@@ -31,5 +41,7 @@ func pauseCmd() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().Duration("timeout", container.DefaultFreezeTimeout, "maximum time to wait for the container to freeze, e.g. 30s")
+
 	return cmd
 }
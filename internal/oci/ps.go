@@ -1,9 +1,17 @@
 package oci
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/nixpig/anocir/internal/container"
 	"github.com/nixpig/anocir/internal/platform"
@@ -12,15 +20,31 @@ import (
 
 func psCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "ps [flags] CONTAINER_ID [ps_args]",
-		Short:   "Display the running processes in a container",
-		Example: "  anocir ps busybox",
+		Use:   "ps [flags] CONTAINER_ID [ps_args]",
+		Short: "Display the running processes in a container",
+		Long: `Display the running processes in a container.
+
+The output format is one of:
+  table          details of each process, read from /proc
+  json           the host PIDs of the processes, compatible with runc
+  json-detailed  details of each process, read from /proc
+
+NSPID is the PID of the process as seen inside the container. When ps_args are
+given, the host ps is run with them and its output filtered to the processes
+in the container.`,
+		Example: "  anocir ps busybox\n  anocir ps --tree busybox\n  anocir ps busybox -- -o pid,comm",
 		Args:    cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			containerID := args[0]
+			psArgs := args[1:]
 
 			rootDir, _ := cmd.Flags().GetString("root")
 			format, _ := cmd.Flags().GetString("format")
+			tree, _ := cmd.Flags().GetBool("tree")
+
+			if len(psArgs) > 0 && format != "table" {
+				return fmt.Errorf("ps_args cannot be used with --format %s", format)
+			}
 
 			cntr, err := container.Load(containerID, rootDir)
 			if err != nil {
@@ -32,15 +56,40 @@ func psCmd() *cobra.Command {
 				return fmt.Errorf("failed to get container state: %w", err)
 			}
 
-			processes, err := platform.GetCgroupProcesses(
-				cntr.GetSpec().Linux.CgroupsPath,
-				state.ID,
-			)
+			pids, err := platform.GetCgroupProcesses(cntr.GetCgroup())
 			if err != nil {
 				return fmt.Errorf("failed to get processes: %w", err)
 			}
 
-			formattedOutput, err := formatProcessesOutput(format, processes)
+			if len(psArgs) > 0 {
+				output, err := exec.Command("ps", psArgs...).Output()
+				if err != nil {
+					return fmt.Errorf("failed to run ps: %w", err)
+				}
+
+				filtered, err := filterPsOutput(string(output), pids)
+				if err != nil {
+					return fmt.Errorf("failed to filter ps output: %w", err)
+				}
+
+				fmt.Fprint(cmd.OutOrStdout(), filtered)
+
+				return nil
+			}
+
+			var processes []*platform.ProcessInfo
+			for _, pid := range pids {
+				info, err := platform.GetProcessInfo(pid)
+				if err != nil {
+					// The process may have exited since the cgroup was read.
+					slog.Debug("failed to get process info", "container_id", state.ID, "pid", pid, "err", err)
+					continue
+				}
+
+				processes = append(processes, info)
+			}
+
+			formattedOutput, err := formatProcessesOutput(format, processes, tree)
 			if err != nil {
 				return fmt.Errorf("failed to format output: %w", err)
 			}
@@ -53,21 +102,61 @@ func psCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringP("format", "f", "table", "format for ps output")
+	cmd.Flags().StringP("format", "f", "table", "format for ps output (table | json | json-detailed)")
+	cmd.Flags().Bool("tree", false, "show the processes as a tree in table format")
 
 	return cmd
 }
 
-// TODO: Maybe we want to display more that just a list of PIDs?
-func formatProcessesOutput(format string, processes []int) (string, error) {
+func formatProcessesOutput(
+	format string,
+	processes []*platform.ProcessInfo,
+	tree bool,
+) (string, error) {
 	switch format {
 	case "table":
 		var b strings.Builder
-		for _, p := range processes {
-			fmt.Fprintf(&b, "%d\n", p)
+		w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintf(w, "USER\tPID\tNSPID\tPPID\tSTAT\tSTIME\tTIME\tRSS\tCOMMAND\n")
+
+		for _, p := range orderProcesses(processes, tree) {
+			fmt.Fprintf(
+				w, "%s\t%d\t%d\t%d\t%s\t%s\t%s\t%d\t%s%s\n",
+				p.info.User,
+				p.info.PID,
+				p.info.NSPID,
+				p.info.PPID,
+				p.info.State,
+				formatStartTime(p.info.StartTime),
+				formatCPUTime(p.info.CPUTime),
+				p.info.RSS/1024,
+				p.prefix,
+				p.info.Command,
+			)
 		}
+
+		if err := w.Flush(); err != nil {
+			return "", fmt.Errorf("create processes output table: %w", err)
+		}
+
 		return b.String(), nil
 	case "json":
+		pids := make([]int, 0, len(processes))
+		for _, p := range processes {
+			pids = append(pids, p.PID)
+		}
+
+		data, err := json.Marshal(pids)
+		if err != nil {
+			return "", fmt.Errorf("create processes output json: %w", err)
+		}
+		return string(data), nil
+	case "json-detailed":
+		if processes == nil {
+			processes = []*platform.ProcessInfo{}
+		}
+
 		data, err := json.Marshal(processes)
 		if err != nil {
 			return "", fmt.Errorf("create processes output json: %w", err)
@@ -77,3 +166,131 @@ func formatProcessesOutput(format string, processes []int) (string, error) {
 		return "", fmt.Errorf("invalid format: %s", format)
 	}
 }
+
+// treeProcess is a process in the table output, along with the prefix that
+// shows its depth in the tree view.
+type treeProcess struct {
+	info   *platform.ProcessInfo
+	prefix string
+}
+
+// orderProcesses orders the given processes by PID. If tree is true, then
+// each process is followed by its children, prefixed to show their depth.
+func orderProcesses(processes []*platform.ProcessInfo, tree bool) []treeProcess {
+	sorted := slices.SortedFunc(
+		slices.Values(processes),
+		func(a, b *platform.ProcessInfo) int { return cmp.Compare(a.PID, b.PID) },
+	)
+
+	ordered := make([]treeProcess, 0, len(sorted))
+
+	if !tree {
+		for _, p := range sorted {
+			ordered = append(ordered, treeProcess{info: p})
+		}
+
+		return ordered
+	}
+
+	children := make(map[int][]*platform.ProcessInfo)
+	pids := make(map[int]bool)
+
+	for _, p := range sorted {
+		pids[p.PID] = true
+	}
+
+	var roots []*platform.ProcessInfo
+	for _, p := range sorted {
+		if pids[p.PPID] && p.PPID != p.PID {
+			children[p.PPID] = append(children[p.PPID], p)
+		} else {
+			roots = append(roots, p)
+		}
+	}
+
+	var walk func(p *platform.ProcessInfo, depth int)
+	walk = func(p *platform.ProcessInfo, depth int) {
+		prefix := ""
+		if depth > 0 {
+			prefix = strings.Repeat("  ", depth-1) + " \\_ "
+		}
+
+		ordered = append(ordered, treeProcess{info: p, prefix: prefix})
+
+		for _, child := range children[p.PID] {
+			walk(child, depth+1)
+		}
+	}
+
+	for _, root := range roots {
+		walk(root, 0)
+	}
+
+	return ordered
+}
+
+// formatStartTime formats the given process start time like ps, i.e. the time
+// for processes started today, otherwise the date.
+func formatStartTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	t = t.Local()
+	now := time.Now()
+
+	if t.Year() == now.Year() && t.YearDay() == now.YearDay() {
+		return t.Format("15:04")
+	}
+
+	return t.Format("Jan02")
+}
+
+// formatCPUTime formats the given cumulative CPU time like ps, i.e. as
+// [DD-]HH:MM:SS.
+func formatCPUTime(d time.Duration) string {
+	seconds := int64(d / time.Second)
+
+	days := seconds / 86400
+	hours := seconds % 86400 / 3600
+	minutes := seconds % 3600 / 60
+	seconds %= 60
+
+	if days > 0 {
+		return fmt.Sprintf("%d-%02d:%02d:%02d", days, hours, minutes, seconds)
+	}
+
+	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
+}
+
+// filterPsOutput filters the given output of the host ps to the header and
+// the lines for the given pids.
+func filterPsOutput(output string, pids []int) (string, error) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+
+	pidIndex := slices.Index(strings.Fields(lines[0]), "PID")
+	if pidIndex == -1 {
+		return "", errors.New("PID field not found in ps output")
+	}
+
+	var b strings.Builder
+	b.WriteString(lines[0] + "\n")
+
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) <= pidIndex {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[pidIndex])
+		if err != nil {
+			return "", fmt.Errorf("parse pid '%s': %w", fields[pidIndex], err)
+		}
+
+		if slices.Contains(pids, pid) {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,154 @@
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nixpig/anocir/internal/container"
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/spf13/cobra"
+)
+
+func reclaimCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reclaim [flags] CONTAINER_ID",
+		Short: "Reclaim memory of a container without killing it",
+		Long: `Reclaim memory of a container without killing it.
+
+The kernel reclaims the --amount of memory from the container cgroup, using
+cgroup v2 memory.reclaim. The amount is a byte size with an optional unit
+suffix, e.g. 512M, or a percentage of the current memory usage, e.g. 25%.
+--swappiness overrides the balance of anonymous memory and page cache that's
+reclaimed, from 0 for page cache only to 200, on kernels that support it.
+
+The memory usage from memory.stat, before and after, is printed in the
+'text' or 'json' --format.`,
+		Example: "  anocir reclaim --amount 256M busybox\n  anocir reclaim --amount 50% --swappiness 0 --freeze busybox",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			containerID := args[0]
+
+			rootDir, _ := cmd.Flags().GetString("root")
+			amount, _ := cmd.Flags().GetString("amount")
+			freeze, _ := cmd.Flags().GetBool("freeze")
+			format, _ := cmd.Flags().GetString("format")
+
+			if format != "text" && format != "json" {
+				return fmt.Errorf("invalid format: %s", format)
+			}
+
+			opts := &container.ReclaimOpts{Freeze: freeze}
+
+			if err := parseReclaimAmount(opts, amount); err != nil {
+				return fmt.Errorf("failed to parse amount: %w", err)
+			}
+
+			if cmd.Flags().Changed("swappiness") {
+				swappiness, _ := cmd.Flags().GetUint64("swappiness")
+				if swappiness > platform.MaxSwappiness {
+					return fmt.Errorf("swappiness must be from 0 to %d", platform.MaxSwappiness)
+				}
+
+				opts.Swappiness = &swappiness
+			}
+
+			cntr, err := container.Load(containerID, rootDir)
+			if err != nil {
+				return fmt.Errorf("failed to load container: %w", err)
+			}
+
+			report, err := cntr.Reclaim(opts)
+			if err != nil {
+				return fmt.Errorf("failed to reclaim memory: %w", err)
+			}
+
+			if err := formatReclaimOutput(cmd.OutOrStdout(), format, report); err != nil {
+				return fmt.Errorf("failed to print reclaim report: %w", err)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("amount", "", "memory to reclaim, e.g. 256M or 25% of the current usage")
+	cmd.Flags().Uint64("swappiness", 0, "swappiness of the reclaim, from 0 (page cache only) to 200")
+	cmd.Flags().Bool("freeze", false, "freeze the container while memory is reclaimed")
+	cmd.Flags().StringP("format", "f", "text", "output format (text | json)")
+
+	cmd.MarkFlagRequired("amount")
+
+	return cmd
+}
+
+// parseReclaimAmount sets the amount of opts from the given byte size or
+// percentage.
+func parseReclaimAmount(opts *container.ReclaimOpts, amount string) error {
+	amount = strings.TrimSpace(amount)
+
+	if number, ok := strings.CutSuffix(amount, "%"); ok {
+		percent, err := strconv.ParseFloat(number, 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return fmt.Errorf("percentage must be greater than 0 and at most 100: %s", amount)
+		}
+
+		opts.Percent = percent
+
+		return nil
+	}
+
+	size, err := parseBytes(amount)
+	if err != nil {
+		return err
+	}
+
+	if size <= 0 {
+		return errors.New("amount must be greater than 0")
+	}
+
+	opts.Amount = uint64(size)
+
+	return nil
+}
+
+func formatReclaimOutput(
+	w io.Writer,
+	format string,
+	report *container.ReclaimReport,
+) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(report)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "\tBEFORE\tAFTER\n")
+	for _, row := range []struct {
+		name          string
+		before, after uint64
+	}{
+		{"current", report.Before.Current, report.After.Current},
+		{"anon", report.Before.Anon, report.After.Anon},
+		{"file", report.Before.File, report.After.File},
+		{"swap", report.Before.Swap, report.After.Swap},
+	} {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", row.name, row.before, row.after)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("Requested %d bytes, reclaimed %d bytes", report.Requested, report.Reclaimed)
+	if !report.Complete {
+		summary += " (the kernel couldn't reclaim the full amount)"
+	}
+
+	_, err := fmt.Fprintln(w, summary)
+
+	return err
+}
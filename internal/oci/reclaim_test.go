@@ -0,0 +1,46 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/nixpig/anocir/internal/container"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReclaimAmount(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		amount  string
+		want    *container.ReclaimOpts
+		wantErr bool
+	}{
+		"test bytes":            {amount: "1024", want: &container.ReclaimOpts{Amount: 1024}},
+		"test unit":             {amount: "256M", want: &container.ReclaimOpts{Amount: 256 << 20}},
+		"test percentage":       {amount: "25%", want: &container.ReclaimOpts{Percent: 25}},
+		"test fractional":       {amount: "12.5%", want: &container.ReclaimOpts{Percent: 12.5}},
+		"test zero":             {amount: "0", wantErr: true},
+		"test unlimited":        {amount: "-1", wantErr: true},
+		"test zero percentage":  {amount: "0%", wantErr: true},
+		"test over 100 percent": {amount: "150%", wantErr: true},
+		"test invalid":          {amount: "lots", wantErr: true},
+		"test empty":            {amount: "", wantErr: true},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			opts := &container.ReclaimOpts{}
+
+			err := parseReclaimAmount(opts, data.amount)
+			if data.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, data.want, opts)
+		})
+	}
+}
@@ -5,13 +5,36 @@ import (
 	"io"
 	"log/slog"
 
+	"github.com/nixpig/anocir/internal/container"
 	"github.com/nixpig/anocir/internal/logging"
+	"github.com/nixpig/anocir/internal/platform"
 	"github.com/spf13/cobra"
 )
 
 var Version = "dev"
 
-const defaultRootDir = "/run/anocir"
+// ExitError is returned by a command that should exit with the given Code,
+// e.g. the exit code of the container process, rather than because it
+// failed. It's only returned for non-zero codes.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// exitWithCode returns an ExitError for the given code, or nil if it's zero.
+// The error isn't printed by cmd, since it isn't a failure of the command.
+func exitWithCode(cmd *cobra.Command, code int) error {
+	if code == 0 {
+		return nil
+	}
+
+	cmd.SilenceErrors = true
+
+	return &ExitError{Code: code}
+}
 
 func RootCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -25,6 +48,7 @@ func RootCmd() *cobra.Command {
 			logFile, _ := cmd.Flags().GetString("log")
 			debug, _ := cmd.Flags().GetBool("debug")
 			logFormat, _ := cmd.Flags().GetString("log-format")
+			lockTimeout, _ := cmd.Flags().GetDuration("lock-timeout")
 
 			w := io.Discard
 			if logFile != "" {
@@ -38,6 +62,8 @@ func RootCmd() *cobra.Command {
 
 			slog.SetDefault(logging.NewLogger(w, debug, logFormat))
 
+			container.SetLockTimeout(lockTimeout)
+
 			return nil
 		},
 	}
@@ -57,15 +83,27 @@ func RootCmd() *cobra.Command {
 		updateCmd(),
 		pauseCmd(),
 		resumeCmd(),
+		runCmd(),
+		monitorCmd(),
+		waitCmd(),
+		gcCmd(),
+		inspectCmd(),
+		eventsCmd(),
+		reclaimCmd(),
 	)
 
-	cmd.PersistentFlags().StringP("root", "", defaultRootDir, "root directory for container state")
+	cmd.PersistentFlags().StringP("root", "", platform.RuntimeDir(), "root directory for container state")
 	cmd.PersistentFlags().StringP("log", "l", "", "destination to write logs")
 	cmd.PersistentFlags().Bool("debug", false, "enable debug logging")
 	cmd.PersistentFlags().StringP("log-format", "", "text", "log format (json | text)")
+	cmd.PersistentFlags().Duration("lock-timeout", 0, "time to wait for a container lock held by another operation, e.g. 5s (0 doesn't wait)")
 
-	// systemd is always used. Flag is unused but provided to satisfy Docker expectation.
-	cmd.PersistentFlags().BoolP("systemd-cgroup", "", false, "not implemented")
+	// The driver is recorded when the container is created, so the flag only
+	// affects create and run. Containers used to always get a systemd scope,
+	// system.slice/anocir-<id>.scope, but without the flag they're now created
+	// in /anocir/<id> by the cgroupfs driver. A systemd slice:prefix:name
+	// cgroupsPath still selects the systemd driver.
+	cmd.PersistentFlags().BoolP("systemd-cgroup", "", false, "manage container cgroups with systemd, rather than directly in the cgroup filesystem (default cgroup /anocir/<id>; implied by a slice:prefix:name cgroupsPath)")
 
 	cmd.CompletionOptions.HiddenDefaultCmd = true
 
@@ -0,0 +1,294 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/nixpig/anocir/internal/container"
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/nixpig/anocir/internal/terminal"
+	"github.com/nixpig/anocir/internal/validation"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+)
+
+// outputDrainTimeout is how long to keep copying the output of the pty once
+// the container process has exited.
+const outputDrainTimeout = time.Second
+
+func runCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "run [flags] CONTAINER_ID",
+		Short:   "Create and start a container, and wait for it to exit",
+		Example: "  anocir run --rm busybox",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !platform.IsUnifiedCgroupsMode() {
+				return errors.New("anocir requires cgroup v2 (unified mode)")
+			}
+
+			containerID := args[0]
+
+			if err := validation.ContainerID(containerID); err != nil {
+				return fmt.Errorf("failed validation: %w", err)
+			}
+
+			bundle, _ := cmd.Flags().GetString("bundle")
+			pidFile, _ := cmd.Flags().GetString("pid-file")
+			rootDir, _ := cmd.Flags().GetString("root")
+			consoleSocket, _ := cmd.Flags().GetString("console-socket")
+			debug, _ := cmd.Flags().GetBool("debug")
+			logFile, _ := cmd.Flags().GetString("log")
+			logFormat, _ := cmd.Flags().GetString("log-format")
+			systemdCgroup, _ := cmd.Flags().GetBool("systemd-cgroup")
+			detach, _ := cmd.Flags().GetBool("detach")
+			rm, _ := cmd.Flags().GetBool("rm")
+
+			if detach && rm {
+				return errors.New("--rm cannot be used with --detach")
+			}
+
+			cntr, err := createContainer(&createOpts{
+				containerID:   containerID,
+				bundle:        bundle,
+				pidFile:       pidFile,
+				rootDir:       rootDir,
+				consoleSocket: consoleSocket,
+				logFile:       logFile,
+				logFormat:     logFormat,
+				debug:         debug,
+				systemdCgroup: systemdCgroup,
+				monitor:       true,
+				stderr:        cmd.ErrOrStderr(),
+			})
+			if err != nil {
+				return err
+			}
+
+			exitCode, err := runContainer(cntr, detach)
+
+			// A container that failed to initialise is already removed.
+			if rm && container.Exists(containerID, rootDir) {
+				if err := cntr.Delete(true); err != nil {
+					slog.Warn("failed to delete container", "container_id", containerID, "err", err)
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to delete container: %s\n", err)
+				}
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if detach {
+				return nil
+			}
+
+			return exitWithCode(cmd, exitCode)
+		},
+	}
+
+	cwd, _ := os.Getwd()
+	cmd.Flags().StringP("bundle", "b", cwd, "path of bundle directory")
+	cmd.Flags().String("console-socket", "", "console socket path")
+	cmd.Flags().String("pid-file", "", "file to write container PID to")
+	cmd.Flags().BoolP("detach", "d", false, "detach from container process after start")
+	cmd.Flags().Bool("rm", false, "delete the container after it exits")
+
+	return cmd
+}
+
+// runContainer initialises and starts the given cntr. Unless detach is true,
+// it stays attached to the container process, forwarding signals and terminal
+// I/O, and returns its exit code once it exits.
+func runContainer(cntr *container.Container, detach bool) (int, error) {
+	spec := cntr.GetSpec()
+	useTerminal := spec.Process != nil &&
+		spec.Process.Terminal &&
+		cntr.ConsoleSocket == ""
+
+	if useTerminal && detach {
+		return 0, errors.New("cannot allocate a terminal when detached without --console-socket")
+	}
+
+	var ptyCh <-chan *os.File
+
+	if useTerminal {
+		socketDir, err := os.MkdirTemp("", "anocir-console-")
+		if err != nil {
+			return 0, fmt.Errorf("failed to create console socket directory: %w", err)
+		}
+		defer os.RemoveAll(socketDir)
+
+		cntr.ConsoleSocket = filepath.Join(socketDir, "console.sock")
+
+		listener, err := net.ListenUnix("unix", &net.UnixAddr{
+			Name: cntr.ConsoleSocket,
+			Net:  "unix",
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to listen on console socket: %w", err)
+		}
+		defer listener.Close()
+
+		ptyCh = receivePty(listener)
+	}
+
+	if err := cntr.Init(); err != nil {
+		return 0, fmt.Errorf("failed to initialise container: %w", err)
+	}
+
+	var master *os.File
+	if useTerminal {
+		master = <-ptyCh
+		if master == nil {
+			return 0, errors.New("failed to receive pty from container")
+		}
+		defer master.Close()
+	}
+
+	// Start listening for signals before the container starts so none are
+	// missed between start and wait.
+	sigCh := make(chan os.Signal, 32)
+	signal.Notify(sigCh, forwardedSignals()...)
+	defer signal.Stop(sigCh)
+
+	if err := cntr.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start container: %w", err)
+	}
+
+	if detach {
+		return 0, nil
+	}
+
+	var outputDone chan struct{}
+
+	if master != nil {
+		stdinFD := int(os.Stdin.Fd())
+
+		if terminal.IsTerminal(stdinFD) {
+			restore, err := terminal.SetRawMode(stdinFD)
+			if err != nil {
+				return 0, fmt.Errorf("failed to set terminal raw mode: %w", err)
+			}
+			defer restore()
+
+			if err := terminal.CopyWinSize(int(master.Fd()), stdinFD); err != nil {
+				slog.Debug("failed to set initial console size", "container_id", cntr.State.ID, "err", err)
+			}
+		}
+
+		outputDone = make(chan struct{})
+
+		go func() {
+			io.Copy(master, os.Stdin)
+		}()
+
+		go func() {
+			io.Copy(os.Stdout, master)
+			close(outputDone)
+		}()
+	}
+
+	exitCh := make(chan int, 1)
+	errCh := make(chan error, 1)
+
+	go func() {
+		status, err := cntr.Wait(0)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		exitCh <- status.ExitCode
+	}()
+
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig == unix.SIGWINCH {
+				if master != nil {
+					if err := terminal.CopyWinSize(int(master.Fd()), int(os.Stdin.Fd())); err != nil {
+						slog.Debug("failed to resize console", "container_id", cntr.State.ID, "err", err)
+					}
+				}
+				continue
+			}
+
+			slog.Debug("forward signal", "container_id", cntr.State.ID, "pid", cntr.State.Pid, "signal", sig)
+			if err := cntr.Signal(sig.(unix.Signal)); err != nil &&
+				!errors.Is(err, platform.ErrProcessNotFound) {
+				slog.Warn("failed to forward signal", "container_id", cntr.State.ID, "signal", sig, "err", err)
+			}
+
+		case exitCode := <-exitCh:
+			// Processes outside the container, e.g. in another PID
+			// namespace, can hold the pty open, so the output is only
+			// drained for so long once the container process has exited.
+			if outputDone != nil {
+				select {
+				case <-outputDone:
+				case <-time.After(outputDrainTimeout):
+					slog.Debug("pty output not drained after container exited", "container_id", cntr.State.ID)
+				}
+			}
+
+			return exitCode, nil
+
+		case err := <-errCh:
+			return 0, fmt.Errorf("failed to wait for container process: %w", err)
+		}
+	}
+}
+
+// receivePty accepts a single connection on the given listener and sends the
+// Pty Master received on it to the returned channel. The channel receives nil
+// if the Pty could not be received.
+func receivePty(listener *net.UnixListener) <-chan *os.File {
+	ch := make(chan *os.File, 1)
+
+	go func() {
+		conn, err := listener.AcceptUnix()
+		if err != nil {
+			slog.Warn("failed to accept on console socket", "err", err)
+			ch <- nil
+			return
+		}
+		defer conn.Close()
+
+		master, err := terminal.ReceivePty(conn)
+		if err != nil {
+			slog.Warn("failed to receive pty", "err", err)
+			ch <- nil
+			return
+		}
+
+		ch <- master
+	}()
+
+	return ch
+}
+
+// forwardedSignals returns the signals that are relayed to the container
+// process. SIGCHLD and SIGURG are excluded as they are used by the runtime
+// itself, while SIGKILL and SIGSTOP cannot be caught.
+func forwardedSignals() []os.Signal {
+	var signals []os.Signal
+
+	for sig := unix.SIGHUP; sig <= unix.SIGSYS; sig++ {
+		switch sig {
+		case unix.SIGCHLD, unix.SIGURG, unix.SIGKILL, unix.SIGSTOP:
+			continue
+		}
+
+		signals = append(signals, sig)
+	}
+
+	return signals
+}
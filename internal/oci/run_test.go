@@ -0,0 +1,49 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestForwardedSignals(t *testing.T) {
+	t.Parallel()
+
+	signals := forwardedSignals()
+
+	assert.Contains(t, signals, unix.SIGTERM)
+	assert.Contains(t, signals, unix.SIGWINCH)
+	assert.NotContains(t, signals, unix.SIGCHLD)
+	assert.NotContains(t, signals, unix.SIGURG)
+	assert.NotContains(t, signals, unix.SIGKILL)
+	assert.NotContains(t, signals, unix.SIGSTOP)
+}
+
+func TestExitWithCode(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		code int
+		err  error
+	}{
+		"test zero exit code": {code: 0, err: nil},
+		"test non-zero exit code": {
+			code: 137,
+			err:  &ExitError{Code: 137},
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := &cobra.Command{}
+
+			err := exitWithCode(cmd, data.code)
+			assert.Equal(t, data.err, err)
+			assert.Equal(t, data.code != 0, cmd.SilenceErrors)
+		})
+	}
+}
@@ -3,21 +3,45 @@ package oci
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 
 	"github.com/nixpig/anocir/internal/container"
+	"github.com/nixpig/anocir/internal/platform"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/spf13/cobra"
 )
 
+// stateOutput is the container state printed by the state command. It extends
+// the OCI state with how the container process exited, once it has.
+type stateOutput struct {
+	*specs.State
+	Exit *container.ExitStatus `json:"exit,omitempty"`
+	// MemoryEvents is the OOM and OOM kill counts of the container cgroup,
+	// while it exists. Once the container has stopped, they're in Exit.
+	MemoryEvents *platform.MemoryEvents `json:"memoryEvents,omitempty"`
+	// Resources is the effective resources of the container. It's only set
+	// with --resources.
+	Resources *specs.LinuxResources `json:"resources,omitempty"`
+	// ResourceDrift is the cgroup values that don't match Resources. It's only
+	// set with --resources.
+	ResourceDrift []platform.ResourceDrift `json:"resourceDrift,omitempty"`
+	// Devices is the device filter of the container cgroup. It's only set
+	// with --devices.
+	Devices *platform.DeviceFilter `json:"devices,omitempty"`
+}
+
 func stateCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "state [flags] CONTAINER_ID",
 		Short:   "Get the state of a container",
-		Example: "  anocir state busybox",
+		Example: "  anocir state busybox\n  anocir state --resources busybox\n  anocir state --devices busybox",
 		Args:    cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			containerID := args[0]
 
 			rootDir, _ := cmd.Flags().GetString("root")
+			showResources, _ := cmd.Flags().GetBool("resources")
+			showDevices, _ := cmd.Flags().GetBool("devices")
 
 			cntr, err := container.Load(containerID, rootDir)
 			if err != nil {
@@ -29,12 +53,50 @@ func stateCmd() *cobra.Command {
 				return fmt.Errorf("failed to get container state: %w", err)
 			}
 
-			stateOutput, err := json.Marshal(state)
+			exitStatus, err := cntr.GetExitStatus()
+			if err != nil {
+				return fmt.Errorf("failed to get container exit status: %w", err)
+			}
+
+			out := &stateOutput{State: state, Exit: exitStatus}
+
+			// The state is still useful without the counts, e.g. if the
+			// container exited after its state was read.
+			if state.Status != specs.StateStopped {
+				if out.MemoryEvents, err = cntr.MemoryEvents(); err != nil {
+					slog.Debug("failed to get memory events", "container_id", containerID, "err", err)
+				}
+			}
+
+			if showResources {
+				if out.Resources, err = cntr.GetResources(); err != nil {
+					return fmt.Errorf("failed to get container resources: %w", err)
+				}
+
+				// A stopped container's cgroup may no longer exist.
+				if state.Status != specs.StateStopped {
+					if out.ResourceDrift, err = cntr.CheckResources(); err != nil {
+						return fmt.Errorf("failed to check container resources: %w", err)
+					}
+				}
+			}
+
+			if showDevices {
+				if state.Status == specs.StateStopped {
+					return fmt.Errorf("cannot get device filter of a stopped container")
+				}
+
+				if out.Devices, err = cntr.DeviceFilter(); err != nil {
+					return fmt.Errorf("failed to get container device filter: %w", err)
+				}
+			}
+
+			output, err := json.Marshal(out)
 			if err != nil {
 				return fmt.Errorf("failed to marshal state: %w", err)
 			}
 
-			if _, err := fmt.Fprintln(cmd.OutOrStdout(), string(stateOutput)); err != nil {
+			if _, err := fmt.Fprintln(cmd.OutOrStdout(), string(output)); err != nil {
 				return fmt.Errorf("failed to print state: %w", err)
 			}
 
@@ -42,5 +104,8 @@ func stateCmd() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().Bool("resources", false, "include the effective resources and any drift of the cgroup from them")
+	cmd.Flags().Bool("devices", false, "include the effective device rules and the device programs attached to the cgroup")
+
 	return cmd
 }
@@ -2,21 +2,49 @@ package oci
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/nixpig/anocir/internal/container"
-	"github.com/nixpig/anocir/internal/platform"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+// byteUnits maps the unit suffixes accepted in byte sizes, e.g. 512M, to
+// their multipliers.
+var byteUnits = map[string]int64{
+	"":  1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+	"p": 1 << 50,
+}
+
 func updateCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:     "update [flags] CONTAINER_ID",
-		Short:   "Update resource limits of existing container",
-		Example: "  anocir update --resources resources.json busybox",
+		Use:   "update [flags] CONTAINER_ID",
+		Short: "Update resource limits of existing container",
+		Long: `Update resource limits of existing container.
+
+Resources are read from the --resources JSON file, if given, and the resource
+flags applied on top. Byte sizes take an optional unit suffix of k, m, g, t or
+p, e.g. 512M or 1.5G, must be a whole number of bytes, and -1 means unlimited.
+
+The update is merged into the effective resources of the container, so only
+the values to change need to be given. Settings in 'unified' are written to
+the cgroup v2 interface files of the same name, after the other resources,
+and the keys applied are printed to stderr. Rules in 'devices' replace the
+current device rules, and the BPF device filter of the cgroup is replaced
+atomically. The effective resources are shown by 'anocir state --resources',
+and the enforced device rules by 'anocir state --devices'.`,
+		Example: "  anocir update --resources resources.json busybox\n  anocir update --memory 512M --cpu-quota 50000 busybox",
 		Args:    cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			containerID := args[0]
@@ -25,27 +53,20 @@ func updateCmd() *cobra.Command {
 			resources, _ := cmd.Flags().GetString("resources")
 
 			var linuxResources specs.LinuxResources
-			var data []byte
-			var err error
-
-			switch resources {
-			case "-":
-				data, err = io.ReadAll(os.Stdin)
-				if err != nil {
-					return fmt.Errorf("failed to read resources from stdin: %w", err)
-				}
-			case "":
-				// TODO: Parse flags.
-				return fmt.Errorf("resources can currently only be read from a resources.json file or passed via stdin")
-			default:
-				data, err = os.ReadFile(resources)
-				if err != nil {
-					return fmt.Errorf("failed to load resources file: %w", err)
+
+			if resources != "" {
+				if err := parseResourcesFile(&linuxResources, resources); err != nil {
+					return fmt.Errorf("failed to parse resources file: %w", err)
 				}
 			}
 
-			if err := json.Unmarshal(data, &linuxResources); err != nil {
-				return fmt.Errorf("failed to parse resources JSON: %w", err)
+			changed, err := parseResourceFlags(&linuxResources, cmd.Flags())
+			if err != nil {
+				return fmt.Errorf("failed to parse resource flags: %w", err)
+			}
+
+			if resources == "" && !changed {
+				return errors.New("no resources to update, pass --resources or resource flags")
 			}
 
 			cntr, err := container.Load(containerID, rootDir)
@@ -53,24 +74,218 @@ func updateCmd() *cobra.Command {
 				return fmt.Errorf("failed to load container: %w", err)
 			}
 
-			state, err := cntr.GetState()
+			unified, err := cntr.UpdateResources(&linuxResources)
 			if err != nil {
-				return fmt.Errorf("failed to get container state: %w", err)
+				return fmt.Errorf("failed to update resources: %w", err)
 			}
 
-			if err := platform.UpdateCgroup(
-				cntr.GetSpec().Linux.CgroupsPath,
-				state.ID,
-				&linuxResources,
-			); err != nil {
-				return fmt.Errorf("failed to update cgroups: %w", err)
+			if len(unified) > 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Applied unified settings: %s\n", strings.Join(unified, ", "))
 			}
 
 			return nil
 		},
 	}
 
-	cmd.Flags().StringP("resources", "r", "resources.json", "path to resources JSON file, pass \"-\" to read from stdin")
+	cmd.Flags().StringP("resources", "r", "", "path to resources JSON file, pass \"-\" to read from stdin")
+
+	addResourceFlags(cmd.Flags())
 
 	return cmd
 }
+
+// addResourceFlags adds the resource flags parsed by parseResourceFlags to
+// flags.
+func addResourceFlags(flags *pflag.FlagSet) {
+	flags.String("memory", "", "memory limit, e.g. 512M")
+	flags.String("memory-reservation", "", "memory soft limit, e.g. 256M")
+	flags.String("memory-swap", "", "total memory and swap limit, e.g. 1G")
+	flags.Uint64("cpu-shares", 0, "CPU shares, i.e. the relative weight against other containers")
+	flags.Uint64("cpu-period", 0, "CPU CFS period in microseconds")
+	flags.Int64("cpu-quota", 0, "CPU CFS quota in microseconds per period")
+	flags.Uint64("cpu-rt-period", 0, "CPU realtime period in microseconds")
+	flags.Int64("cpu-rt-runtime", 0, "CPU realtime runtime in microseconds per period")
+	flags.String("cpuset-cpus", "", "CPUs the container can use, e.g. 0-3,5")
+	flags.String("cpuset-mems", "", "memory nodes the container can use, e.g. 0,1")
+	flags.Int64("pids-limit", 0, "maximum number of processes, -1 for unlimited")
+	flags.Uint16("blkio-weight", 0, "block IO weight, from 10 to 1000")
+	flags.String("l3-cache-schema", "", "Intel RDT L3 cache schema (not supported)")
+	flags.String("mem-bw-schema", "", "Intel RDT memory bandwidth schema (not supported)")
+
+	// runc names the flag cpu-share.
+	flags.Uint64("cpu-share", 0, "CPU shares")
+	flags.MarkHidden("cpu-share")
+}
+
+func parseResourcesFile(resources *specs.LinuxResources, path string) error {
+	var data []byte
+	var err error
+
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("read resources: %w", err)
+	}
+
+	if err := json.Unmarshal(data, resources); err != nil {
+		return fmt.Errorf("parse resources JSON: %w", err)
+	}
+
+	return nil
+}
+
+// parseResourceFlags applies the resource flags that were set to resources,
+// reporting whether any were set.
+func parseResourceFlags(
+	resources *specs.LinuxResources,
+	flags *pflag.FlagSet,
+) (bool, error) {
+	for _, name := range []string{"l3-cache-schema", "mem-bw-schema"} {
+		if flags.Changed(name) {
+			return false, fmt.Errorf("--%s is not supported, Intel RDT is not implemented", name)
+		}
+	}
+
+	changed := false
+
+	memory := func() *specs.LinuxMemory {
+		if resources.Memory == nil {
+			resources.Memory = &specs.LinuxMemory{}
+		}
+
+		return resources.Memory
+	}
+
+	cpu := func() *specs.LinuxCPU {
+		if resources.CPU == nil {
+			resources.CPU = &specs.LinuxCPU{}
+		}
+
+		return resources.CPU
+	}
+
+	for name, field := range map[string]func(int64){
+		"memory":             func(v int64) { memory().Limit = &v },
+		"memory-reservation": func(v int64) { memory().Reservation = &v },
+		"memory-swap":        func(v int64) { memory().Swap = &v },
+	} {
+		if !flags.Changed(name) {
+			continue
+		}
+
+		s, _ := flags.GetString(name)
+
+		v, err := parseBytes(s)
+		if err != nil {
+			return false, fmt.Errorf("parse --%s: %w", name, err)
+		}
+
+		field(v)
+		changed = true
+	}
+
+	for _, name := range []string{"cpu-shares", "cpu-share"} {
+		if flags.Changed(name) {
+			shares, _ := flags.GetUint64(name)
+			cpu().Shares = &shares
+			changed = true
+		}
+	}
+
+	if flags.Changed("cpu-period") {
+		period, _ := flags.GetUint64("cpu-period")
+		cpu().Period = &period
+		changed = true
+	}
+
+	if flags.Changed("cpu-quota") {
+		quota, _ := flags.GetInt64("cpu-quota")
+		cpu().Quota = &quota
+		changed = true
+	}
+
+	if flags.Changed("cpu-rt-period") {
+		period, _ := flags.GetUint64("cpu-rt-period")
+		cpu().RealtimePeriod = &period
+		changed = true
+	}
+
+	if flags.Changed("cpu-rt-runtime") {
+		runtime, _ := flags.GetInt64("cpu-rt-runtime")
+		cpu().RealtimeRuntime = &runtime
+		changed = true
+	}
+
+	if flags.Changed("cpuset-cpus") {
+		cpu().Cpus, _ = flags.GetString("cpuset-cpus")
+		changed = true
+	}
+
+	if flags.Changed("cpuset-mems") {
+		cpu().Mems, _ = flags.GetString("cpuset-mems")
+		changed = true
+	}
+
+	if flags.Changed("pids-limit") {
+		limit, _ := flags.GetInt64("pids-limit")
+		resources.Pids = &specs.LinuxPids{Limit: &limit}
+		changed = true
+	}
+
+	if flags.Changed("blkio-weight") {
+		weight, _ := flags.GetUint16("blkio-weight")
+		if resources.BlockIO == nil {
+			resources.BlockIO = &specs.LinuxBlockIO{}
+		}
+		resources.BlockIO.Weight = &weight
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// parseBytes parses a byte size with an optional binary unit suffix, e.g.
+// 512M, 1.5g, 64KiB or 1024. The value -1 is returned as is.
+func parseBytes(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "-1" {
+		return -1, nil
+	}
+
+	lower := strings.ToLower(s)
+	lower = strings.TrimSuffix(lower, "ib")
+	lower = strings.TrimSuffix(lower, "b")
+
+	i := strings.IndexFunc(lower, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i == -1 {
+		i = len(lower)
+	}
+
+	number, unit := lower[:i], lower[i:]
+
+	multiplier, ok := byteUnits[unit]
+	if !ok || number == "" {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+
+	size := v * float64(multiplier)
+	if size > math.MaxInt64 {
+		return 0, fmt.Errorf("size too large: %s", s)
+	}
+
+	if size != math.Trunc(size) {
+		return 0, fmt.Errorf("size isn't a whole number of bytes: %s", s)
+	}
+
+	return int64(size), nil
+}
@@ -0,0 +1,133 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBytes(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		size    string
+		bytes   int64
+		wantErr bool
+	}{
+		"test plain number":  {size: "1024", bytes: 1024},
+		"test unlimited":     {size: "-1", bytes: -1},
+		"test megabytes":     {size: "512M", bytes: 512 << 20},
+		"test lowercase":     {size: "2g", bytes: 2 << 30},
+		"test byte suffix":   {size: "64kb", bytes: 64 << 10},
+		"test iec suffix":    {size: "64KiB", bytes: 64 << 10},
+		"test fraction":      {size: "1.5G", bytes: 3 << 29},
+		"test part byte":     {size: "1.5", wantErr: true},
+		"test part kilobyte": {size: "0.3k", wantErr: true},
+		"test invalid unit":  {size: "10X", wantErr: true},
+		"test no number":     {size: "M", wantErr: true},
+		"test empty":         {size: "", wantErr: true},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			bytes, err := parseBytes(data.size)
+			if data.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, data.bytes, bytes)
+		})
+	}
+}
+
+func TestParseResourceFlags(t *testing.T) {
+	t.Parallel()
+
+	limit := int64(1 << 30)
+	reservation := int64(256 << 20)
+	shares := uint64(512)
+	quota := int64(50000)
+	pids := int64(100)
+	weight := uint16(500)
+	swappiness := uint64(10)
+
+	scenarios := map[string]struct {
+		args      []string
+		resources *specs.LinuxResources
+		want      *specs.LinuxResources
+		changed   bool
+		wantErr   bool
+	}{
+		"test no flags": {
+			args:      []string{},
+			resources: &specs.LinuxResources{},
+			want:      &specs.LinuxResources{},
+			changed:   false,
+		},
+		"test flags": {
+			args: []string{
+				"--memory", "1G",
+				"--memory-reservation", "256M",
+				"--cpu-shares", "512",
+				"--cpu-quota", "50000",
+				"--cpuset-cpus", "0-3",
+				"--pids-limit", "100",
+				"--blkio-weight", "500",
+			},
+			resources: &specs.LinuxResources{},
+			want: &specs.LinuxResources{
+				Memory:  &specs.LinuxMemory{Limit: &limit, Reservation: &reservation},
+				CPU:     &specs.LinuxCPU{Shares: &shares, Quota: &quota, Cpus: "0-3"},
+				Pids:    &specs.LinuxPids{Limit: &pids},
+				BlockIO: &specs.LinuxBlockIO{Weight: &weight},
+			},
+			changed: true,
+		},
+		"test flags merge with file resources": {
+			args: []string{"--memory", "1G", "--cpu-share", "512"},
+			resources: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Swappiness: &swappiness},
+			},
+			want: &specs.LinuxResources{
+				Memory: &specs.LinuxMemory{Limit: &limit, Swappiness: &swappiness},
+				CPU:    &specs.LinuxCPU{Shares: &shares},
+			},
+			changed: true,
+		},
+		"test invalid size": {
+			args:      []string{"--memory", "lots"},
+			resources: &specs.LinuxResources{},
+			wantErr:   true,
+		},
+		"test intel rdt": {
+			args:      []string{"--l3-cache-schema", "L3:0=ff"},
+			resources: &specs.LinuxResources{},
+			wantErr:   true,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			flags := updateCmd().Flags()
+			require.NoError(t, flags.Parse(data.args))
+
+			changed, err := parseResourceFlags(data.resources, flags)
+			if data.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, data.changed, changed)
+			assert.Equal(t, data.want, data.resources)
+		})
+	}
+}
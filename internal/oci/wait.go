@@ -0,0 +1,113 @@
+package oci
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nixpig/anocir/internal/container"
+	"github.com/spf13/cobra"
+)
+
+// waitResult holds the outcome of waiting for a single container.
+type waitResult struct {
+	id     string
+	status *container.ExitStatus
+	err    error
+}
+
+func waitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "wait [flags] CONTAINER_ID [CONTAINER_ID...]",
+		Short: "Wait for containers to stop and return their exit codes",
+		Long: `Wait for containers to stop and return their exit codes.
+
+When waiting for a single container, its exit code is printed and used as the
+exit code of the command. When waiting for multiple containers, the ID and
+exit code of each container is printed. By default the command waits for all
+of the containers and exits with the first non-zero exit code, in the order
+the containers were given. With --any it exits with the exit code of the first
+container to stop.
+
+Exit codes are recorded by the monitor process, so only containers started by
+run, or created with --monitor, can be waited for.`,
+		Example: "  anocir wait busybox\n  anocir wait --any --timeout 30s busybox alpine",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rootDir, _ := cmd.Flags().GetString("root")
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			waitAny, _ := cmd.Flags().GetBool("any")
+
+			results := make(chan waitResult, len(args))
+
+			for _, id := range args {
+				go func() {
+					cntr, err := container.Load(id, rootDir)
+					if err != nil {
+						results <- waitResult{id: id, err: fmt.Errorf("failed to load container: %w", err)}
+						return
+					}
+
+					status, err := cntr.Wait(timeout)
+					results <- waitResult{id: id, status: status, err: err}
+				}()
+			}
+
+			if waitAny {
+				var errs []error
+
+				for range args {
+					r := <-results
+					if r.err != nil {
+						errs = append(errs, fmt.Errorf("container %s: %w", r.id, r.err))
+						continue
+					}
+
+					fmt.Fprintf(cmd.OutOrStdout(), "%s %d\n", r.id, r.status.ExitCode)
+					return exitWithCode(cmd, r.status.ExitCode)
+				}
+
+				return fmt.Errorf("failed to wait for containers: %w", errors.Join(errs...))
+			}
+
+			statuses := make(map[string]*container.ExitStatus, len(args))
+
+			var errs []error
+			for range args {
+				r := <-results
+				if r.err != nil {
+					errs = append(errs, fmt.Errorf("container %s: %w", r.id, r.err))
+					continue
+				}
+
+				statuses[r.id] = r.status
+			}
+
+			if len(errs) > 0 {
+				return fmt.Errorf("failed to wait for containers: %w", errors.Join(errs...))
+			}
+
+			if len(args) == 1 {
+				exitCode := statuses[args[0]].ExitCode
+				fmt.Fprintln(cmd.OutOrStdout(), exitCode)
+				return exitWithCode(cmd, exitCode)
+			}
+
+			exitCode := 0
+			for _, id := range args {
+				status := statuses[id]
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %d\n", id, status.ExitCode)
+
+				if exitCode == 0 {
+					exitCode = status.ExitCode
+				}
+			}
+
+			return exitWithCode(cmd, exitCode)
+		},
+	}
+
+	cmd.Flags().Duration("timeout", 0, "maximum time to wait, e.g. 30s (0 waits indefinitely)")
+	cmd.Flags().Bool("any", false, "return as soon as any of the containers stops")
+
+	return cmd
+}
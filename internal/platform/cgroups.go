@@ -1,39 +1,185 @@
 package platform
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"math"
+	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/containerd/cgroups/v3"
 	"github.com/containerd/cgroups/v3/cgroup2"
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
+// cgroupRoot is the mount point of the unified cgroup hierarchy.
+const cgroupRoot = "/sys/fs/cgroup"
+
+const (
+	// defaultSystemdSlice is the slice of the container scope when the
+	// cgroupsPath doesn't give one.
+	defaultSystemdSlice = "system.slice"
+
+	// defaultSystemdUserSlice is the slice, in the systemd user instance, of
+	// the container scope when the cgroupsPath doesn't give one.
+	defaultSystemdUserSlice = "user.slice"
+
+	// cgroupfsParent is the parent of the cgroups created by the cgroupfs
+	// driver when the cgroupsPath is empty.
+	cgroupfsParent = "/anocir"
+
+	// cgroupDeleteTimeout is how long to wait for the processes in a cgroup
+	// managed by the cgroupfs driver to exit before giving up on deleting it.
+	cgroupDeleteTimeout = 5 * time.Second
+
+	// cgroupDeleteRetryInterval is how often to retry deleting a cgroup whose
+	// processes haven't exited yet.
+	cgroupDeleteRetryInterval = 10 * time.Millisecond
+
+	// cgroupMemoryUnlimited is written for memory limits of -1. The cgroup2
+	// package writes memory limits as numbers, and the kernel rejects -1 but
+	// caps larger values, reporting this one as max.
+	cgroupMemoryUnlimited = math.MaxInt64
+)
+
+// CgroupDriver is how container cgroups are managed.
+type CgroupDriver string
+
+const (
+	// CgroupfsDriver manages cgroups directly in the cgroup filesystem.
+	CgroupfsDriver CgroupDriver = "cgroupfs"
+	// SystemdDriver manages cgroups as transient systemd units, over D-Bus.
+	SystemdDriver CgroupDriver = "systemd"
+	// SystemdUserDriver manages cgroups as transient units of the systemd
+	// user instance of the current user, in its delegated subtree.
+	SystemdUserDriver CgroupDriver = "systemd-user"
+	// NoCgroupDriver is used when an unprivileged user has no systemd user
+	// instance to manage cgroups through, so the container has no cgroup.
+	NoCgroupDriver CgroupDriver = "none"
+)
+
+// ErrNoCgroup is returned for a cgroup with the NoCgroupDriver.
+var ErrNoCgroup = errors.New("container has no cgroup")
+
+// Cgroup identifies the cgroup of a container.
+type Cgroup struct {
+	Driver CgroupDriver
+	// CgroupsPath is the cgroupsPath from the container spec. The systemd
+	// driver expects slice:prefix:name, and the cgroupfs driver a path in the
+	// cgroup hierarchy, e.g. /mygroup/ctr1.
+	CgroupsPath string
+	ContainerID string
+}
+
 // IsUnifiedCgroupsMode checks whether unified mode (i.e. cgroups v2) is
 // running on the host.
 func IsUnifiedCgroupsMode() bool {
 	return cgroups.Mode() == cgroups.Unified
 }
 
-// CreateCgroup uses the given cgroupsPath and containerID to create a systemd
-// cgroup slice and group, placing the process specified by containerPID into
-// it and applying the resource restrictions from the given resources.
+// CreateCgroup creates the given cgroup, placing the process specified by
+// containerPID into it and applying the resource restrictions from the given
+// resources. The device rules in resources are enforced as given, so should
+// be the effective rules from DeviceRules. It returns the keys of the unified
+// settings that were applied.
 func CreateCgroup(
-	cgroupsPath, containerID string,
+	cg Cgroup,
 	containerPID int,
 	resources *specs.LinuxResources,
-) error {
-	slice, group := buildSystemdCGroupSliceAndGroup(cgroupsPath, containerID)
-
-	if err := validateCgroupsSliceGroup(slice, group); err != nil {
-		return fmt.Errorf("validate cgroup slice and group: %w", err)
+) ([]string, error) {
+	if cg.Driver == NoCgroupDriver {
+		return nil, nil
 	}
 
 	cgResources := &cgroup2.Resources{}
 	if resources != nil {
-		cgResources = cgroup2.ToResources(resources)
+		cgResources = toCgroupResources(resources)
+
+		if err := validateUnifiedKeys(resources.Unified); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := createCgroup(cg, containerPID, cgResources); err != nil {
+		return nil, err
+	}
+
+	if resources == nil {
+		return nil, nil
+	}
+
+	if err := setCgroupDevices(cg, resources.Devices); err != nil {
+		return nil, fmt.Errorf("set device filter: %w", err)
+	}
+
+	return applyCgroupUnified(cg, resources.Unified)
+}
+
+// toCgroupResources converts the given resources to those written to the
+// cgroup interface files. Unlike cgroup2.ToResources, memory limits and CPU
+// quotas of -1 are unlimited, a CPU quota is applied without a period, and
+// the swap limit is only reduced by a memory limit that's set.
+func toCgroupResources(resources *specs.LinuxResources) *cgroup2.Resources {
+	cgResources := cgroup2.ToResources(resources)
+
+	if c := resources.CPU; c != nil && (c.Quota != nil || c.Period != nil) {
+		quota := c.Quota
+		if quota != nil && *quota < 0 {
+			quota = nil
+		}
+
+		cgResources.CPU.Max = cgroup2.NewCPUMax(quota, c.Period)
+	}
+
+	m := resources.Memory
+	if m == nil {
+		return cgResources
+	}
+
+	unlimited := func(v *int64) *int64 {
+		if v == nil || *v != -1 {
+			return v
+		}
+
+		limit := int64(cgroupMemoryUnlimited)
+		return &limit
+	}
+
+	cgResources.Memory.Max = unlimited(m.Limit)
+	cgResources.Memory.Low = unlimited(m.Reservation)
+	cgResources.Memory.Swap = unlimited(m.Swap)
+
+	// The spec swap limit is of memory and swap together, but the cgroup only
+	// limits swap.
+	if m.Swap != nil && *m.Swap != -1 && m.Limit != nil && *m.Limit != -1 {
+		swap := *m.Swap - *m.Limit
+		cgResources.Memory.Swap = &swap
+	}
+
+	return cgResources
+}
+
+func createCgroup(
+	cg Cgroup,
+	containerPID int,
+	cgResources *cgroup2.Resources,
+) error {
+	switch cg.Driver {
+	case CgroupfsDriver:
+		return createCgroupfs(cg, containerPID, cgResources)
+	case SystemdUserDriver:
+		return createSystemdUserCgroup(cg, containerPID, cgResources)
+	}
+
+	slice, group := systemdSliceAndGroup(cg)
+
+	if err := validateCgroupsSliceGroup(slice, group); err != nil {
+		return fmt.Errorf("validate cgroup slice and group: %w", err)
 	}
 
 	manager, err := cgroup2.NewSystemd(slice, group, containerPID, cgResources)
@@ -51,75 +197,121 @@ func CreateCgroup(
 	return nil
 }
 
-// DeleteCgroup loads the cgroup manager using the given cgroupsPath and
-// containerID, and deletes the corresponding cgroup.
-func DeleteCgroup(cgroupsPath, containerID string) error {
-	cg, err := loadCgroupManager(cgroupsPath, containerID)
+func createCgroupfs(
+	cg Cgroup,
+	containerPID int,
+	resources *cgroup2.Resources,
+) error {
+	group, err := buildCgroupfsGroup(cg.CgroupsPath, cg.ContainerID)
 	if err != nil {
-		return fmt.Errorf("load cgroup manager: %w", err)
+		return fmt.Errorf("build cgroup path: %w", err)
 	}
 
-	if err := cg.Freeze(); err != nil {
-		slog.Warn("failed to freeze cgroup before delete", "err", err)
+	manager, err := cgroup2.NewManager(cgroupRoot, group, resources)
+	if err != nil {
+		return fmt.Errorf("create cgroup: %w", err)
 	}
 
-	if err := cg.Kill(); err != nil {
-		slog.Warn("failed to kill cgroup before delete", "err", err)
+	if err := manager.AddProc(uint64(containerPID)); err != nil {
+		return fmt.Errorf("add process to cgroup: %w", err)
 	}
 
-	return cg.DeleteSystemd()
+	return nil
 }
 
-// UpdateCgroup loads the cgroup manager using the given cgroupsPath and
-// containerID, and applies the given resources restrictions.
-func UpdateCgroup(
-	cgroupsPath, containerID string,
-	resources *specs.LinuxResources,
-) error {
-	cg, err := loadCgroupManager(cgroupsPath, containerID)
+// DeleteCgroup kills any processes in the given cgroup and deletes it.
+func DeleteCgroup(cg Cgroup) error {
+	if cg.Driver == NoCgroupDriver {
+		return nil
+	}
+
+	manager, err := loadCgroupManager(cg)
 	if err != nil {
 		return fmt.Errorf("load cgroup manager: %w", err)
 	}
 
-	cgResources := &cgroup2.Resources{}
-	if resources != nil {
-		cgResources = cgroup2.ToResources(resources)
+	if cg.Driver == CgroupfsDriver {
+		path, err := cgroupPath(cg)
+		if err != nil {
+			return err
+		}
+
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
 	}
 
-	return cg.Update(cgResources)
-}
+	// Frozen processes, e.g. of a paused container, are thawed so they exit
+	// as soon as they're killed. If Kill can't use cgroup.kill, it freezes the
+	// cgroup itself while it signals the processes.
+	if err := manager.Thaw(); err != nil {
+		slog.Warn("failed to thaw cgroup before delete", "err", err)
+	}
 
-// FreezeCgroup loads the cgroup manager using the given cgroupsPath and
-// containerID, and freezes it.
-func FreezeCgroup(cgroupsPath, containerID string) error {
-	cg, err := loadCgroupManager(cgroupsPath, containerID)
-	if err != nil {
-		return fmt.Errorf("load cgroup manager: %w", err)
+	if err := manager.Kill(); err != nil {
+		slog.Warn("failed to kill cgroup before delete", "err", err)
 	}
 
-	return cg.Freeze()
+	switch cg.Driver {
+	case SystemdDriver:
+		return manager.DeleteSystemd()
+	case SystemdUserDriver:
+		return stopSystemdUserUnit(cg)
+	}
+
+	// The killed processes exit asynchronously, and the cgroup can't be
+	// removed until they have.
+	deadline := time.Now().Add(cgroupDeleteTimeout)
+	for {
+		err := manager.Delete()
+		if err == nil || time.Now().After(deadline) {
+			return err
+		}
+
+		time.Sleep(cgroupDeleteRetryInterval)
+	}
 }
 
-// ThawCgroup loads the cgroup manager using the given cgroupsPath and
-// containerID, and thaws it.
-func ThawCgroup(cgroupsPath, containerID string) error {
-	cg, err := loadCgroupManager(cgroupsPath, containerID)
+// UpdateCgroup applies the given resources restrictions to the given cgroup.
+// It returns the keys of the unified settings that were applied.
+func UpdateCgroup(cg Cgroup, resources *specs.LinuxResources) ([]string, error) {
+	manager, err := loadCgroupManager(cg)
 	if err != nil {
-		return fmt.Errorf("load cgroup manager: %w", err)
+		return nil, fmt.Errorf("load cgroup manager: %w", err)
+	}
+
+	cgResources := &cgroup2.Resources{}
+	if resources != nil {
+		cgResources = toCgroupResources(resources)
+
+		if err := validateUnifiedKeys(resources.Unified); err != nil {
+			return nil, err
+		}
 	}
 
-	return cg.Thaw()
+	if err := manager.Update(cgResources); err != nil {
+		return nil, err
+	}
+
+	if resources == nil {
+		return nil, nil
+	}
+
+	if err := setCgroupDevices(cg, resources.Devices); err != nil {
+		return nil, fmt.Errorf("set device filter: %w", err)
+	}
+
+	return applyCgroupUnified(cg, resources.Unified)
 }
 
-// GetCgroupProcesses loads the cgroup manager using the given cgroupsPath and
-// containerID, and returns a list of the contained process IDs
-func GetCgroupProcesses(cgroupsPath, containerID string) ([]int, error) {
-	cg, err := loadCgroupManager(cgroupsPath, containerID)
+// GetCgroupProcesses returns a list of the process IDs in the given cgroup.
+func GetCgroupProcesses(cg Cgroup) ([]int, error) {
+	manager, err := loadCgroupManager(cg)
 	if err != nil {
 		return nil, fmt.Errorf("load cgroup manager: %w", err)
 	}
 
-	cgProcesses, err := cg.Procs(true)
+	cgProcesses, err := manager.Procs(true)
 	if err != nil {
 		return nil, fmt.Errorf("load cgroup2 processes: %w", err)
 	}
@@ -132,21 +324,304 @@ func GetCgroupProcesses(cgroupsPath, containerID string) ([]int, error) {
 	return processes, nil
 }
 
-func loadCgroupManager(cgroupsPath, containerID string) (*cgroup2.Manager, error) {
-	slice, group := buildSystemdCGroupSliceAndGroup(cgroupsPath, containerID)
+// ContainerCgroup is a cgroup created for a container using the default
+// naming, i.e. a systemd scope anocir-<id>.scope in any slice, or a cgroupfs
+// cgroup /anocir/<id>.
+type ContainerCgroup struct {
+	// Path is the path of the cgroup in the cgroup filesystem.
+	Path string
+	// Cgroup identifies the cgroup, for use with the other cgroup functions.
+	Cgroup Cgroup
+	// Processes is the number of processes in the cgroup.
+	Processes int
+}
+
+// FindContainerCgroups returns the cgroups created for containers with the
+// default naming.
+func FindContainerCgroups() ([]ContainerCgroup, error) {
+	return findContainerCgroups(cgroupRoot)
+}
+
+func findContainerCgroups(root string) ([]ContainerCgroup, error) {
+	var cgroups []ContainerCgroup
+	cgroupfsDir := filepath.Join(root, cgroupfsParent)
+
+	// The systemd user instance of the current user, if it isn't root.
+	userManagerDir := ""
+	if os.Geteuid() != 0 {
+		userManagerDir = filepath.Join(root, systemdUserManagerCgroup())
+	}
+
+	if err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// cgroups can be removed while walking.
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		// Containers of other users are managed by their own systemd user
+		// instance, and must be left alone.
+		if strings.HasPrefix(d.Name(), "user@") &&
+			strings.HasSuffix(d.Name(), ".service") &&
+			path != userManagerDir {
+			return filepath.SkipDir
+		}
+
+		var cg Cgroup
+
+		if filepath.Dir(path) == cgroupfsDir {
+			cg = Cgroup{
+				Driver:      CgroupfsDriver,
+				CgroupsPath: filepath.Join(cgroupfsParent, d.Name()),
+				ContainerID: d.Name(),
+			}
+		} else {
+			id, ok := strings.CutPrefix(d.Name(), "anocir-")
+			if !ok || !strings.HasSuffix(id, ".scope") {
+				return nil
+			}
+			id = strings.TrimSuffix(id, ".scope")
+
+			driver := SystemdDriver
+			sliceRoot := root
+			if userManagerDir != "" && strings.HasPrefix(path, userManagerDir+"/") {
+				driver = SystemdUserDriver
+				sliceRoot = userManagerDir
+			}
+
+			slice := filepath.Base(filepath.Dir(path))
+			if filepath.Dir(path) == sliceRoot {
+				slice = "-"
+			}
+
+			cg = Cgroup{
+				Driver:      driver,
+				CgroupsPath: fmt.Sprintf("%s:anocir:%s", slice, id),
+				ContainerID: id,
+			}
+		}
+
+		processes, err := countCgroupProcesses(path)
+		if err != nil {
+			return err
+		}
+
+		cgroups = append(cgroups, ContainerCgroup{
+			Path:      path,
+			Cgroup:    cg,
+			Processes: processes,
+		})
+
+		return filepath.SkipDir
+	}); err != nil {
+		return nil, fmt.Errorf("walk cgroups: %w", err)
+	}
+
+	return cgroups, nil
+}
+
+// CgroupInfo identifies the cgroup of a container.
+type CgroupInfo struct {
+	Driver CgroupDriver `json:"driver"`
+	// Slice is the systemd slice the container scope is in, when using the
+	// systemd driver.
+	Slice string `json:"slice,omitempty"`
+	// Scope is the systemd scope, or slice, of the container, when using the
+	// systemd driver.
+	Scope string `json:"scope,omitempty"`
+	// Path is the path of the cgroup in the cgroup filesystem.
+	Path string `json:"path"`
+}
+
+// GetCgroupInfo returns the CgroupInfo for the given cgroup.
+func GetCgroupInfo(cg Cgroup) (*CgroupInfo, error) {
+	path, err := cgroupPath(cg)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &CgroupInfo{Driver: cg.Driver, Path: path}
+
+	if cg.Driver != CgroupfsDriver {
+		info.Slice, info.Scope = systemdSliceAndGroup(cg)
+	}
+
+	return info, nil
+}
+
+// countCgroupProcesses returns the number of processes in the cgroup at path,
+// including those in its sub-cgroups, e.g. exec sub-cgroups.
+func countCgroupProcesses(path string) (int, error) {
+	count := 0
+
+	if err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		procs, err := os.ReadFile(filepath.Join(p, "cgroup.procs"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("read cgroup processes: %w", err)
+		}
+
+		count += len(strings.Fields(string(procs)))
+
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// expandSlice returns the path of the given systemd unit in the cgroup
+// hierarchy. Slices are nested by the dashes in their name, e.g. a-b.slice
+// is at a.slice/a-b.slice.
+func expandSlice(unit string) string {
+	name, ok := strings.CutSuffix(unit, ".slice")
+	if !ok || name == "-" {
+		return unit
+	}
+
+	var path string
+	for i, c := range name {
+		if c == '-' {
+			path = filepath.Join(path, name[:i]+".slice")
+		}
+	}
+
+	return filepath.Join(path, unit)
+}
+
+func loadCgroupManager(cg Cgroup) (*cgroup2.Manager, error) {
+	if cg.Driver == NoCgroupDriver {
+		return nil, ErrNoCgroup
+	}
+
+	if cg.Driver == CgroupfsDriver {
+		group, err := buildCgroupfsGroup(cg.CgroupsPath, cg.ContainerID)
+		if err != nil {
+			return nil, fmt.Errorf("build cgroup path: %w", err)
+		}
+
+		return cgroup2.Load(group, cgroup2.WithMountpoint(cgroupRoot))
+	}
+
+	if cg.Driver == SystemdUserDriver {
+		path, err := cgroupPath(cg)
+		if err != nil {
+			return nil, err
+		}
+
+		return cgroup2.Load(
+			strings.TrimPrefix(path, cgroupRoot),
+			cgroup2.WithMountpoint(cgroupRoot),
+		)
+	}
+
+	slice, group := systemdSliceAndGroup(cg)
 
 	if err := validateCgroupsSliceGroup(slice, group); err != nil {
 		return nil, fmt.Errorf("validate cgroup slice and group: %w", err)
 	}
 
 	// LoadSystemd always returns a nil error, ignore it.
-	cg, _ := cgroup2.LoadSystemd(slice, group)
+	manager, _ := cgroup2.LoadSystemd(slice, group)
+
+	return manager, nil
+}
+
+// cgroupPath returns the path of the given cgroup in the cgroup filesystem.
+func cgroupPath(cg Cgroup) (string, error) {
+	if cg.Driver == NoCgroupDriver {
+		return "", ErrNoCgroup
+	}
+
+	if cg.Driver == CgroupfsDriver {
+		group, err := buildCgroupfsGroup(cg.CgroupsPath, cg.ContainerID)
+		if err != nil {
+			return "", fmt.Errorf("build cgroup path: %w", err)
+		}
+
+		return filepath.Join(cgroupRoot, group), nil
+	}
+
+	slice, group := systemdSliceAndGroup(cg)
+
+	if err := validateCgroupsSliceGroup(slice, group); err != nil {
+		return "", fmt.Errorf("validate cgroup slice and group: %w", err)
+	}
+
+	root := cgroupRoot
+	if cg.Driver == SystemdUserDriver {
+		root = filepath.Join(cgroupRoot, systemdUserManagerCgroup())
+	}
+
+	return filepath.Join(root, expandSlice(slice), expandSlice(group)), nil
+}
+
+// systemdSliceAndGroup returns the systemd slice and unit of the given cgroup,
+// which uses one of the systemd drivers.
+func systemdSliceAndGroup(cg Cgroup) (string, string) {
+	defaultSlice := defaultSystemdSlice
+	if cg.Driver == SystemdUserDriver {
+		defaultSlice = defaultSystemdUserSlice
+	}
+
+	return buildSystemdCGroupSliceAndGroup(cg.CgroupsPath, cg.ContainerID, defaultSlice)
+}
 
-	return cg, nil
+// IsSystemdCgroupsPath checks whether the given cgroupsPath is in the
+// slice:prefix:name form used by the systemd drivers.
+func IsSystemdCgroupsPath(cgroupsPath string) bool {
+	return strings.Contains(cgroupsPath, ":")
+}
+
+// buildCgroupfsGroup returns the cgroup for the given cgroupsPath, relative to
+// the cgroup root. Relative paths are also taken from the cgroup root, and an
+// empty cgroupsPath is /anocir/<containerID>.
+func buildCgroupfsGroup(cgroupsPath, containerID string) (string, error) {
+	if cgroupsPath == "" {
+		if containerID == "" {
+			return "", errors.New("empty cgroupsPath and container ID")
+		}
+
+		cgroupsPath = filepath.Join(cgroupfsParent, containerID)
+	}
+
+	if IsSystemdCgroupsPath(cgroupsPath) {
+		return "", fmt.Errorf("'%s' is a systemd cgroupsPath, which requires the systemd cgroup driver", cgroupsPath)
+	}
+
+	if slices.Contains(strings.Split(cgroupsPath, "/"), "..") {
+		return "", fmt.Errorf("'%s' contains directory traversal", cgroupsPath)
+	}
+
+	group := filepath.Join("/", cgroupsPath)
+	if group == "/" {
+		return "", errors.New("cgroupsPath can't be the cgroup root")
+	}
+
+	return group, nil
 }
 
 func buildSystemdCGroupSliceAndGroup(
-	cgroupsPath, containerID string,
+	cgroupsPath, containerID, defaultSlice string,
 ) (string, string) {
 	if cgroupsPath != "" && strings.Contains(cgroupsPath, ":") {
 		parts := strings.SplitN(cgroupsPath, ":", 3)
@@ -155,7 +630,7 @@ func buildSystemdCGroupSliceAndGroup(
 
 		switch slice {
 		case "":
-			slice = "system.slice"
+			slice = defaultSlice
 		case "-":
 			slice = "/"
 		}
@@ -183,13 +658,18 @@ func buildSystemdCGroupSliceAndGroup(
 	}
 
 	if containerID == "" {
-		return "system.slice", ""
+		return defaultSlice, ""
 	}
 
-	return "system.slice", fmt.Sprintf("anocir-%s.scope", containerID)
+	return defaultSlice, fmt.Sprintf("anocir-%s.scope", containerID)
 }
 
 func validateCgroupsSliceGroup(slice, group string) error {
+	// The root slice, given as "-" in the cgroupsPath.
+	if slice == "/" {
+		slice = ""
+	}
+
 	for _, s := range []string{slice, group} {
 		if s == "" {
 			continue
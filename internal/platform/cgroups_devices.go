@@ -0,0 +1,350 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"slices"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/containerd/cgroups/v3/cgroup2"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// allowedDeviceRules is the device rules the container is always allowed, on
+// top of the default devices and the devices in the spec.
+var allowedDeviceRules = []specs.LinuxDeviceCgroup{
+	// Creating device nodes, but not using them.
+	{Allow: true, Type: CharDevice, Access: "m"},
+	{Allow: true, Type: BlockDevice, Access: "m"},
+	// /dev/console
+	{Allow: true, Type: CharDevice, Major: deviceNumber(5), Minor: deviceNumber(1), Access: "rwm"},
+	// /dev/ptmx
+	{Allow: true, Type: CharDevice, Major: deviceNumber(5), Minor: deviceNumber(2), Access: "rwm"},
+	// /dev/pts/*
+	{Allow: true, Type: CharDevice, Major: deviceNumber(136), Access: "rwm"},
+}
+
+// DeviceFilter is the BPF device filter of a cgroup.
+type DeviceFilter struct {
+	// Rules is the effective device rules, from which the filter is compiled.
+	Rules []specs.LinuxDeviceCgroup `json:"rules"`
+	// Tag is the kernel tag of the program compiled from Rules.
+	Tag string `json:"tag,omitempty"`
+	// Programs is the device programs attached to the cgroup.
+	Programs []DeviceProgram `json:"programs"`
+	// Enforced is whether the program compiled from Rules is attached to the
+	// cgroup.
+	Enforced bool `json:"enforced"`
+}
+
+// DeviceProgram is a BPF device program attached to a cgroup.
+type DeviceProgram struct {
+	ID  uint32 `json:"id"`
+	Tag string `json:"tag"`
+}
+
+// DeviceRules returns the device rules to enforce for the given rules and
+// devices, i.e. the rules followed by those allowing the default devices, the
+// given devices and allowedDeviceRules. Later rules take precedence. If there
+// are no rules, devices aren't controlled and nil is returned.
+func DeviceRules(
+	rules []specs.LinuxDeviceCgroup,
+	devices []specs.LinuxDevice,
+) []specs.LinuxDeviceCgroup {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	effective := make([]specs.LinuxDeviceCgroup, 0, len(rules))
+	for _, r := range rules {
+		effective = append(effective, normaliseDeviceRule(r))
+	}
+
+	for _, d := range slices.Concat(defaultDevices, devices) {
+		deviceType := d.Type
+		switch deviceType {
+		case UnbufferedCharDevice:
+			deviceType = CharDevice
+		case FifoDevice:
+			// FIFOs aren't controlled by the device cgroup.
+			continue
+		}
+
+		effective = append(effective, specs.LinuxDeviceCgroup{
+			Allow:  true,
+			Type:   deviceType,
+			Major:  deviceNumber(d.Major),
+			Minor:  deviceNumber(d.Minor),
+			Access: "rwm",
+		})
+	}
+
+	for _, r := range allowedDeviceRules {
+		effective = append(effective, normaliseDeviceRule(r))
+	}
+
+	return effective
+}
+
+// normaliseDeviceRule returns the given rule with the defaults the spec
+// allows to be left out filled in, i.e. all types and -1 for any major or
+// minor, as the rule compiler expects.
+func normaliseDeviceRule(rule specs.LinuxDeviceCgroup) specs.LinuxDeviceCgroup {
+	if rule.Type == "" {
+		rule.Type = AllDevices
+	}
+
+	if rule.Major == nil {
+		rule.Major = deviceNumber(-1)
+	}
+
+	if rule.Minor == nil {
+		rule.Minor = deviceNumber(-1)
+	}
+
+	return rule
+}
+
+// GetCgroupDeviceFilter returns the DeviceFilter of the given cgroup for the
+// given effective rules.
+func GetCgroupDeviceFilter(
+	cg Cgroup,
+	rules []specs.LinuxDeviceCgroup,
+) (*DeviceFilter, error) {
+	path, err := cgroupPath(cg)
+	if err != nil {
+		return nil, fmt.Errorf("get cgroup path: %w", err)
+	}
+
+	filter := &DeviceFilter{Rules: rules, Programs: []DeviceProgram{}}
+
+	if len(rules) > 0 {
+		spec, err := deviceFilterSpec(rules)
+		if err != nil {
+			return nil, err
+		}
+
+		if filter.Tag, err = spec.Tag(); err != nil {
+			return nil, fmt.Errorf("calculate device filter tag: %w", err)
+		}
+	}
+
+	dirFD, err := unix.Open(path, unix.O_DIRECTORY|unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open cgroup: %w", err)
+	}
+	defer unix.Close(dirFD)
+
+	attached, err := attachedDevicePrograms(dirFD)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range attached {
+		prog, err := ebpf.NewProgramFromID(id)
+		if err != nil {
+			return nil, fmt.Errorf("open device program %d: %w", id, err)
+		}
+
+		info, err := prog.Info()
+		prog.Close()
+		if err != nil {
+			return nil, fmt.Errorf("get device program %d info: %w", id, err)
+		}
+
+		filter.Programs = append(filter.Programs, DeviceProgram{
+			ID:  uint32(id),
+			Tag: info.Tag,
+		})
+
+		if filter.Tag != "" && info.Tag == filter.Tag {
+			filter.Enforced = true
+		}
+	}
+
+	return filter, nil
+}
+
+// setCgroupDevices compiles the given device rules into a BPF program and
+// attaches it to the given cgroup, replacing any device programs already
+// attached. If there are no rules, the cgroup is left as it is.
+func setCgroupDevices(cg Cgroup, rules []specs.LinuxDeviceCgroup) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	path, err := cgroupPath(cg)
+	if err != nil {
+		return fmt.Errorf("get cgroup path: %w", err)
+	}
+
+	spec, err := deviceFilterSpec(rules)
+	if err != nil {
+		return err
+	}
+
+	prog, err := ebpf.NewProgram(spec)
+	if err != nil {
+		return fmt.Errorf("load device filter: %w", err)
+	}
+	// The kernel holds its own reference to the program once it's attached.
+	defer prog.Close()
+
+	dirFD, err := unix.Open(path, unix.O_DIRECTORY|unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("open cgroup: %w", err)
+	}
+	defer unix.Close(dirFD)
+
+	attached, err := attachedDevicePrograms(dirFD)
+	if err != nil {
+		return err
+	}
+
+	if err := attachDeviceFilter(dirFD, prog, attached); err != nil {
+		// Attaching BPF programs needs privileges in the initial user
+		// namespace, so devices can't be controlled in rootless mode.
+		if IsRootless() && errors.Is(err, unix.EPERM) {
+			slog.Warn("device rules not enforced in rootless mode", "cgroup", path, "err", err)
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// deviceFilterSpec compiles the given device rules into the spec of a BPF
+// device program.
+func deviceFilterSpec(rules []specs.LinuxDeviceCgroup) (*ebpf.ProgramSpec, error) {
+	insts, license, err := cgroup2.DeviceFilter(rules)
+	if err != nil {
+		return nil, fmt.Errorf("compile device rules: %w", err)
+	}
+
+	return &ebpf.ProgramSpec{
+		Type:         ebpf.CGroupDevice,
+		Instructions: insts,
+		License:      license,
+	}, nil
+}
+
+// attachDeviceFilter attaches prog to the cgroup of dirFD in place of the
+// attached programs. A single attached program is replaced atomically.
+// Otherwise, prog is attached before the others are detached, so devices are
+// never uncontrolled in between.
+func attachDeviceFilter(
+	dirFD int,
+	prog *ebpf.Program,
+	attached []ebpf.ProgramID,
+) error {
+	if len(attached) == 1 {
+		old, err := ebpf.NewProgramFromID(attached[0])
+		if err != nil {
+			return fmt.Errorf("open device program %d: %w", attached[0], err)
+		}
+		defer old.Close()
+
+		err = replaceProgram(dirFD, prog, old)
+		if err == nil {
+			return nil
+		}
+
+		// BPF_F_REPLACE was only added in Linux 5.6.
+		slog.Debug("failed to replace device program", "id", attached[0], "err", err)
+	}
+
+	if err := link.RawAttachProgram(link.RawAttachProgramOptions{
+		Target:  dirFD,
+		Program: prog,
+		Attach:  ebpf.AttachCGroupDevice,
+		Flags:   unix.BPF_F_ALLOW_MULTI,
+	}); err != nil {
+		return fmt.Errorf("attach device filter: %w", err)
+	}
+
+	for _, id := range attached {
+		old, err := ebpf.NewProgramFromID(id)
+		if err != nil {
+			return fmt.Errorf("open device program %d: %w", id, err)
+		}
+
+		err = link.RawDetachProgram(link.RawDetachProgramOptions{
+			Target:  dirFD,
+			Program: old,
+			Attach:  ebpf.AttachCGroupDevice,
+		})
+		old.Close()
+		if err != nil {
+			return fmt.Errorf("detach device program %d: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// bpfProgAttachAttr is the BPF_PROG_ATTACH layout of union bpf_attr.
+type bpfProgAttachAttr struct {
+	targetFD     uint32
+	attachBPFFD  uint32
+	attachType   uint32
+	attachFlags  uint32
+	replaceBPFFD uint32
+}
+
+// replaceProgram attaches prog to the cgroup of dirFD in place of old, in a
+// single BPF_PROG_ATTACH. It's called directly, since link.RawAttachProgram
+// doesn't set BPF_F_REPLACE for a ReplaceProgram anchor.
+func replaceProgram(dirFD int, prog, old *ebpf.Program) error {
+	attr := bpfProgAttachAttr{
+		targetFD:     uint32(dirFD),
+		attachBPFFD:  uint32(prog.FD()),
+		attachType:   uint32(ebpf.AttachCGroupDevice),
+		attachFlags:  unix.BPF_F_ALLOW_MULTI | unix.BPF_F_REPLACE,
+		replaceBPFFD: uint32(old.FD()),
+	}
+
+	_, _, errno := unix.Syscall(
+		unix.SYS_BPF,
+		unix.BPF_PROG_ATTACH,
+		uintptr(unsafe.Pointer(&attr)),
+		unsafe.Sizeof(attr),
+	)
+	runtime.KeepAlive(prog)
+	runtime.KeepAlive(old)
+
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// attachedDevicePrograms returns the IDs of the device programs attached to
+// the cgroup of dirFD.
+func attachedDevicePrograms(dirFD int) ([]ebpf.ProgramID, error) {
+	result, err := link.QueryPrograms(link.QueryOptions{
+		Target: dirFD,
+		Attach: ebpf.AttachCGroupDevice,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("query device programs: %w", err)
+	}
+
+	ids := make([]ebpf.ProgramID, 0, len(result.Programs))
+	for _, p := range result.Programs {
+		ids = append(ids, p.ID)
+	}
+
+	return ids, nil
+}
+
+func deviceNumber(n int64) *int64 {
+	return &n
+}
@@ -0,0 +1,175 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/cgroups/v3/cgroup2"
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// threadedControllers is the controllers that can be enabled for the children
+// of a cgroup that has processes of its own.
+var threadedControllers = []string{"cpu", "cpuset", "perf_event", "pids"}
+
+// execCgroupPattern matches the names of the exec sub-cgroups named after the
+// PID of the runtime process that created them.
+var execCgroupPattern = regexp.MustCompile(`^exec-([0-9]+)$`)
+
+// CreateExecCgroup creates the sub-cgroup at subPath in the given container
+// cgroup, if it doesn't exist, and applies the given resources to it. It
+// returns the sub-cgroup directory, for the exec process to be started in,
+// which the caller must close.
+func CreateExecCgroup(
+	cg Cgroup,
+	subPath string,
+	resources *specs.LinuxResources,
+) (*os.File, error) {
+	if err := validateExecCgroupPath(subPath); err != nil {
+		return nil, err
+	}
+
+	path, err := cgroupPath(cg)
+	if err != nil {
+		return nil, fmt.Errorf("get cgroup path: %w", err)
+	}
+
+	parent, err := cgroup2.Load(
+		strings.TrimPrefix(path, cgroupRoot),
+		cgroup2.WithMountpoint(cgroupRoot),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("load cgroup: %w", err)
+	}
+
+	cgResources := &cgroup2.Resources{}
+	if resources != nil {
+		cgResources = toCgroupResources(resources)
+	}
+
+	if err := checkExecControllers(cgResources.EnabledControllers()); err != nil {
+		return nil, err
+	}
+
+	if _, err := parent.NewChild(subPath, cgResources); err != nil {
+		return nil, fmt.Errorf("create exec cgroup: %w", err)
+	}
+
+	dir, err := os.Open(filepath.Join(path, subPath))
+	if err != nil {
+		return nil, fmt.Errorf("open exec cgroup: %w", err)
+	}
+
+	return dir, nil
+}
+
+// DeleteExecCgroup kills any processes left in the sub-cgroup at subPath in
+// the given container cgroup and deletes it.
+func DeleteExecCgroup(cg Cgroup, subPath string) error {
+	if err := validateExecCgroupPath(subPath); err != nil {
+		return err
+	}
+
+	path, err := cgroupPath(cg)
+	if err != nil {
+		return fmt.Errorf("get cgroup path: %w", err)
+	}
+
+	manager, err := cgroup2.Load(
+		strings.TrimPrefix(filepath.Join(path, subPath), cgroupRoot),
+		cgroup2.WithMountpoint(cgroupRoot),
+	)
+	if err != nil {
+		return fmt.Errorf("load exec cgroup: %w", err)
+	}
+
+	if err := manager.Kill(); err != nil {
+		return fmt.Errorf("kill exec cgroup processes: %w", err)
+	}
+
+	return manager.Delete()
+}
+
+// checkExecControllers checks the given controllers can be enabled for an
+// exec sub-cgroup. The container process is always in the container cgroup,
+// and a cgroup with processes of its own can't distribute domain resources,
+// e.g. memory, to its children, so only threaded controllers can be.
+func checkExecControllers(controllers []string) error {
+	var domain []string
+	for _, c := range controllers {
+		if !slices.Contains(threadedControllers, c) {
+			domain = append(domain, c)
+		}
+	}
+
+	if len(domain) > 0 {
+		return fmt.Errorf(
+			"%s can't be limited for exec, only %s can",
+			strings.Join(domain, ", "),
+			strings.Join(threadedControllers, ", "),
+		)
+	}
+
+	return nil
+}
+
+// FindStaleExecCgroups returns the exec-<pid> sub-cgroups of the container
+// cgroup at path that have no processes and whose runtime process has exited,
+// i.e. those left behind by detached exec processes.
+func FindStaleExecCgroups(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("read cgroup directory: %w", err)
+	}
+
+	var stale []string
+
+	for _, entry := range entries {
+		match := execCgroupPattern.FindStringSubmatch(entry.Name())
+		if !entry.IsDir() || match == nil {
+			continue
+		}
+
+		// The runtime process creates the sub-cgroup before starting the
+		// exec process in it, so it's only stale once the runtime has exited.
+		pid, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+
+		alive, err := IsProcessAlive(pid, 0)
+		if err != nil || alive {
+			continue
+		}
+
+		procs, err := countCgroupProcesses(filepath.Join(path, entry.Name()))
+		if err != nil || procs > 0 {
+			continue
+		}
+
+		stale = append(stale, entry.Name())
+	}
+
+	return stale, nil
+}
+
+// validateExecCgroupPath checks the given path of an exec sub-cgroup is
+// within the container cgroup.
+func validateExecCgroupPath(subPath string) error {
+	if !filepath.IsLocal(subPath) {
+		return fmt.Errorf("exec cgroup path must be relative to the container cgroup: %s", subPath)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package platform
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// freezerPollInterval is how often cgroup.events is checked while waiting for
+// a cgroup to freeze or thaw.
+const freezerPollInterval = 10 * time.Millisecond
+
+// ErrFreezeTimeout is returned when the processes of a cgroup aren't all
+// frozen in time, e.g. because some are in uninterruptible sleep.
+var ErrFreezeTimeout = errors.New("timed out waiting for cgroup to freeze")
+
+// FreezeCgroup freezes the given cgroup, including any sub-cgroups, and waits
+// up to timeout for cgroup.events to report all its processes are frozen. If
+// they aren't, the cgroup is thawed and an ErrFreezeTimeout error returned.
+func FreezeCgroup(cg Cgroup, timeout time.Duration) error {
+	path, err := cgroupPath(cg)
+	if err != nil {
+		return fmt.Errorf("get cgroup path: %w", err)
+	}
+
+	return freezeCgroup(path, timeout)
+}
+
+// ThawCgroup thaws the given cgroup and waits for cgroup.events to report it
+// isn't frozen.
+func ThawCgroup(cg Cgroup) error {
+	path, err := cgroupPath(cg)
+	if err != nil {
+		return fmt.Errorf("get cgroup path: %w", err)
+	}
+
+	if err := setCgroupFreeze(path, false); err != nil {
+		return err
+	}
+
+	// Thawing doesn't wait on the processes, so is quick.
+	thawed, err := waitCgroupFrozen(path, false, time.Second)
+	if err != nil {
+		return err
+	}
+
+	if !thawed {
+		return errors.New("timed out waiting for cgroup to thaw")
+	}
+
+	return nil
+}
+
+func freezeCgroup(path string, timeout time.Duration) error {
+	if err := setCgroupFreeze(path, true); err != nil {
+		return err
+	}
+
+	frozen, err := waitCgroupFrozen(path, true, timeout)
+	if err == nil && frozen {
+		return nil
+	}
+
+	if err == nil {
+		err = fmt.Errorf(
+			"%w after %s, processes may be in uninterruptible sleep",
+			ErrFreezeTimeout, timeout,
+		)
+	}
+
+	if thawErr := setCgroupFreeze(path, false); thawErr != nil {
+		return errors.Join(err, fmt.Errorf("thaw cgroup: %w", thawErr))
+	}
+
+	return err
+}
+
+func setCgroupFreeze(path string, frozen bool) error {
+	value := "0"
+	if frozen {
+		value = "1"
+	}
+
+	if err := os.WriteFile(
+		filepath.Join(path, "cgroup.freeze"),
+		[]byte(value),
+		0o644,
+	); err != nil {
+		return fmt.Errorf("write cgroup.freeze: %w", err)
+	}
+
+	return nil
+}
+
+// waitCgroupFrozen waits up to timeout for cgroup.events of the cgroup at path
+// to report the given frozen state. It returns whether it did in time.
+func waitCgroupFrozen(
+	path string,
+	frozen bool,
+	timeout time.Duration,
+) (bool, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		current, err := isCgroupFrozen(path)
+		if err != nil {
+			return false, err
+		}
+
+		if current == frozen {
+			return true, nil
+		}
+
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+
+		time.Sleep(freezerPollInterval)
+	}
+}
+
+// isCgroupFrozen returns whether cgroup.events of the cgroup at path reports
+// it's frozen, i.e. all of its processes, and those of its descendants, are.
+func isCgroupFrozen(path string) (bool, error) {
+	f, err := os.Open(filepath.Join(path, "cgroup.events"))
+	if err != nil {
+		return false, fmt.Errorf("open cgroup.events: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if ok && key == "frozen" {
+			return value == "1", nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("read cgroup.events: %w", err)
+	}
+
+	return false, errors.New("cgroup.events has no frozen state")
+}
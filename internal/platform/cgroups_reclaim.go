@@ -0,0 +1,100 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/containerd/cgroups/v3/cgroup2/stats"
+	"golang.org/x/sys/unix"
+)
+
+// MaxSwappiness is the highest swappiness that can be passed to
+// memory.reclaim.
+const MaxSwappiness = 200
+
+// MemoryUsage is the memory usage of a cgroup, in bytes.
+type MemoryUsage struct {
+	// Current is the total memory usage, i.e. memory.current.
+	Current uint64 `json:"current"`
+	// Anon is the anonymous memory, e.g. heap and stack.
+	Anon uint64 `json:"anon"`
+	// File is the page cache.
+	File uint64 `json:"file"`
+	// Swap is the swap usage, i.e. memory.swap.current.
+	Swap uint64 `json:"swap"`
+}
+
+// GetCgroupMemoryUsage returns the MemoryUsage of the given cgroup.
+func GetCgroupMemoryUsage(cg Cgroup) (*MemoryUsage, error) {
+	manager, err := loadCgroupManager(cg)
+	if err != nil {
+		return nil, fmt.Errorf("load cgroup manager: %w", err)
+	}
+
+	metrics, err := manager.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("load cgroup2 stats: %w", err)
+	}
+
+	return convertMemoryUsage(metrics.Memory), nil
+}
+
+// ReclaimCgroupMemory asks the kernel to reclaim amount bytes of memory from
+// the given cgroup, using the given swappiness if it's not nil. It reports
+// whether the whole amount was reclaimed, rather than returning an error if
+// it wasn't.
+func ReclaimCgroupMemory(
+	cg Cgroup,
+	amount uint64,
+	swappiness *uint64,
+) (bool, error) {
+	path, err := cgroupPath(cg)
+	if err != nil {
+		return false, fmt.Errorf("get cgroup path: %w", err)
+	}
+
+	return reclaimMemory(path, amount, swappiness)
+}
+
+func reclaimMemory(path string, amount uint64, swappiness *uint64) (bool, error) {
+	if swappiness != nil && *swappiness > MaxSwappiness {
+		return false, fmt.Errorf("swappiness must be from 0 to %d: %d", MaxSwappiness, *swappiness)
+	}
+
+	request := strconv.FormatUint(amount, 10)
+	if swappiness != nil {
+		request += " swappiness=" + strconv.FormatUint(*swappiness, 10)
+	}
+
+	if err := os.WriteFile(
+		filepath.Join(path, "memory.reclaim"),
+		[]byte(request),
+		0o644,
+	); err != nil {
+		// The kernel fails the write with EAGAIN when it reclaimed less than
+		// the amount asked for.
+		if errors.Is(err, unix.EAGAIN) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("write memory.reclaim: %w", err)
+	}
+
+	return true, nil
+}
+
+func convertMemoryUsage(memory *stats.MemoryStat) *MemoryUsage {
+	if memory == nil {
+		return &MemoryUsage{}
+	}
+
+	return &MemoryUsage{
+		Current: memory.Usage,
+		Anon:    memory.Anon,
+		File:    memory.File,
+		Swap:    memory.SwapUsage,
+	}
+}
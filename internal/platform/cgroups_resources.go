@@ -0,0 +1,205 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// ResourceDrift is a cgroup interface file whose value doesn't match the
+// resources applied to the cgroup, e.g. because it was changed by another
+// tool. An empty Actual means the file doesn't exist.
+type ResourceDrift struct {
+	File     string `json:"file"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// CheckCgroupResources compares the interface files of the given cgroup with
+// the given resources, returning the files that don't match.
+func CheckCgroupResources(
+	cg Cgroup,
+	resources *specs.LinuxResources,
+) ([]ResourceDrift, error) {
+	path, err := cgroupPath(cg)
+	if err != nil {
+		return nil, fmt.Errorf("get cgroup path: %w", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("stat cgroup: %w", err)
+	}
+
+	return checkCgroupResources(path, resources)
+}
+
+func checkCgroupResources(
+	path string,
+	resources *specs.LinuxResources,
+) ([]ResourceDrift, error) {
+	expected := expectedCgroupValues(resources)
+
+	files := make([]string, 0, len(expected))
+	for file := range expected {
+		files = append(files, file)
+	}
+	slices.Sort(files)
+
+	var drift []ResourceDrift
+	for _, file := range files {
+		var actual string
+
+		data, err := os.ReadFile(filepath.Join(path, file))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("read %s: %w", file, err)
+		}
+		if err == nil {
+			actual = normaliseCgroupValue(file, string(data))
+		}
+
+		if actual != expected[file] {
+			drift = append(drift, ResourceDrift{
+				File:     file,
+				Expected: expected[file],
+				Actual:   actual,
+			})
+		}
+	}
+
+	return drift, nil
+}
+
+// expectedCgroupValues returns the values the interface files of a cgroup
+// should have after the given resources are applied, keyed by filename. They
+// come from the same conversion as when the resources are applied, formatted
+// as the kernel reports them.
+func expectedCgroupValues(resources *specs.LinuxResources) map[string]string {
+	values := make(map[string]string)
+
+	if resources == nil {
+		return values
+	}
+
+	cgResources := toCgroupResources(resources)
+
+	if m := cgResources.Memory; m != nil {
+		for file, v := range map[string]*int64{
+			"memory.max":      m.Max,
+			"memory.low":      m.Low,
+			"memory.swap.max": m.Swap,
+		} {
+			if v != nil {
+				values[file] = memoryValue(*v)
+			}
+		}
+	}
+
+	if c := cgResources.CPU; c != nil {
+		if c.Weight != nil && *c.Weight != 0 {
+			values["cpu.weight"] = strconv.FormatUint(*c.Weight, 10)
+		}
+
+		if c.Max != "" {
+			values["cpu.max"] = string(c.Max)
+		}
+
+		if c.Cpus != "" {
+			values["cpuset.cpus"] = normaliseCPUList(c.Cpus)
+		}
+
+		if c.Mems != "" {
+			values["cpuset.mems"] = normaliseCPUList(c.Mems)
+		}
+	}
+
+	if p := cgResources.Pids; p != nil && p.Max != 0 {
+		values["pids.max"] = "max"
+		if p.Max > 0 {
+			values["pids.max"] = strconv.FormatInt(p.Max, 10)
+		}
+	}
+
+	return values
+}
+
+// memoryValue formats the given memory size as the kernel reports it, i.e.
+// rounded down to a whole number of pages, or max if it's negative or at the
+// kernel's limit, e.g. cgroupMemoryUnlimited.
+func memoryValue(size int64) string {
+	pageSize := int64(os.Getpagesize())
+
+	if size < 0 || size/pageSize >= cgroupMemoryUnlimited/pageSize {
+		return "max"
+	}
+
+	return strconv.FormatInt(size/pageSize*pageSize, 10)
+}
+
+// normaliseCgroupValue normalises the given contents of the given interface
+// file so it can be compared with an expected value.
+func normaliseCgroupValue(file, value string) string {
+	value = strings.TrimSpace(value)
+
+	if file == "cpuset.cpus" || file == "cpuset.mems" {
+		return normaliseCPUList(value)
+	}
+
+	return value
+}
+
+// normaliseCPUList normalises the given list of CPUs or memory nodes, e.g.
+// 3,0-1,2 to 0-3. Invalid lists are returned as is.
+func normaliseCPUList(list string) string {
+	var ids []int
+
+	for part := range strings.SplitSeq(strings.TrimSpace(list), ",") {
+		if part == "" {
+			continue
+		}
+
+		start, end, isRange := strings.Cut(part, "-")
+
+		first, err := strconv.Atoi(start)
+		if err != nil {
+			return list
+		}
+
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(end); err != nil || last < first {
+				return list
+			}
+		}
+
+		for id := first; id <= last; id++ {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	var ranges []string
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+
+		if i == j {
+			ranges = append(ranges, strconv.Itoa(ids[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", ids[i], ids[j]))
+		}
+
+		i = j + 1
+	}
+
+	return strings.Join(ranges, ",")
+}
@@ -0,0 +1,313 @@
+package platform
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/containerd/cgroups/v3/cgroup2"
+	"github.com/containerd/cgroups/v3/cgroup2/stats"
+)
+
+// CgroupStats is the resource usage of a cgroup, in the format of the stats
+// reported by runc events.
+type CgroupStats struct {
+	CPU     CPUStats                `json:"cpu"`
+	Memory  MemoryStats             `json:"memory"`
+	Pids    PidsStats               `json:"pids"`
+	Blkio   BlkioStats              `json:"blkio"`
+	Hugetlb map[string]HugetlbStats `json:"hugetlb,omitempty"`
+}
+
+// PSIData is the pressure stall information for one kind of stall.
+type PSIData struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	// Total is the total stall time, in microseconds.
+	Total uint64 `json:"total"`
+}
+
+// PSIStats is the pressure stall information of a resource.
+type PSIStats struct {
+	Some PSIData `json:"some,omitzero"`
+	Full PSIData `json:"full,omitzero"`
+}
+
+// CPUUsage is the CPU time used, in nanoseconds.
+type CPUUsage struct {
+	Total  uint64 `json:"total,omitempty"`
+	Kernel uint64 `json:"kernel"`
+	User   uint64 `json:"user"`
+}
+
+// Throttling is the CPU bandwidth throttling of a cgroup.
+type Throttling struct {
+	Periods          uint64 `json:"periods,omitempty"`
+	ThrottledPeriods uint64 `json:"throttledPeriods,omitempty"`
+	// ThrottledTime is the total time throttled, in nanoseconds.
+	ThrottledTime uint64 `json:"throttledTime,omitempty"`
+}
+
+type CPUStats struct {
+	Usage      CPUUsage   `json:"usage,omitzero"`
+	Throttling Throttling `json:"throttling,omitzero"`
+	PSI        *PSIStats  `json:"psi,omitempty"`
+}
+
+// MemoryEntry is the usage and limit of a kind of memory, in bytes.
+type MemoryEntry struct {
+	Limit   uint64 `json:"limit"`
+	Usage   uint64 `json:"usage,omitempty"`
+	Max     uint64 `json:"max,omitempty"`
+	Failcnt uint64 `json:"failcnt"`
+}
+
+type MemoryStats struct {
+	Cache uint64      `json:"cache,omitempty"`
+	Usage MemoryEntry `json:"usage,omitzero"`
+	// Swap is the combined memory and swap usage, for compatibility with
+	// cgroup v1.
+	Swap MemoryEntry `json:"swap,omitzero"`
+	// Raw is the contents of memory.stat.
+	Raw map[string]uint64 `json:"raw,omitempty"`
+	PSI *PSIStats         `json:"psi,omitempty"`
+}
+
+type PidsStats struct {
+	Current uint64 `json:"current,omitempty"`
+	Limit   uint64 `json:"limit,omitempty"`
+}
+
+// BlkioEntry is a single IO counter of a block device.
+type BlkioEntry struct {
+	Major uint64 `json:"major,omitempty"`
+	Minor uint64 `json:"minor,omitempty"`
+	Op    string `json:"op,omitempty"`
+	Value uint64 `json:"value,omitempty"`
+}
+
+type BlkioStats struct {
+	IoServiceBytesRecursive []BlkioEntry `json:"ioServiceBytesRecursive,omitempty"`
+	IoServicedRecursive     []BlkioEntry `json:"ioServicedRecursive,omitempty"`
+	PSI                     *PSIStats    `json:"psi,omitempty"`
+}
+
+type HugetlbStats struct {
+	Usage   uint64 `json:"usage,omitempty"`
+	Max     uint64 `json:"max,omitempty"`
+	Failcnt uint64 `json:"failcnt"`
+}
+
+// MemoryEvents is the number of times each memory event occurred in a cgroup.
+type MemoryEvents struct {
+	OOM     uint64 `json:"oom"`
+	OOMKill uint64 `json:"oomKill"`
+}
+
+// GetCgroupStats returns the resource usage of the given cgroup.
+func GetCgroupStats(cg Cgroup) (*CgroupStats, error) {
+	manager, err := loadCgroupManager(cg)
+	if err != nil {
+		return nil, fmt.Errorf("load cgroup manager: %w", err)
+	}
+
+	metrics, err := manager.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("load cgroup2 stats: %w", err)
+	}
+
+	return convertCgroupStats(metrics), nil
+}
+
+// GetCgroupMemoryEvents returns the memory event counts of the given cgroup.
+func GetCgroupMemoryEvents(cg Cgroup) (MemoryEvents, error) {
+	manager, err := loadCgroupManager(cg)
+	if err != nil {
+		return MemoryEvents{}, fmt.Errorf("load cgroup manager: %w", err)
+	}
+
+	metrics, err := manager.Stat()
+	if err != nil {
+		return MemoryEvents{}, fmt.Errorf("load cgroup2 stats: %w", err)
+	}
+
+	events := metrics.GetMemoryEvents()
+	if events == nil {
+		return MemoryEvents{}, nil
+	}
+
+	return MemoryEvents{OOM: events.Oom, OOMKill: events.OomKill}, nil
+}
+
+// WatchCgroupMemoryEvents watches memory.events of the given cgroup, sending
+// the event counts each time they change. The channels are closed when the
+// cgroup is empty or deleted.
+func WatchCgroupMemoryEvents(cg Cgroup) (<-chan MemoryEvents, <-chan error, error) {
+	manager, err := loadCgroupManager(cg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load cgroup manager: %w", err)
+	}
+
+	cgroupEvents, cgroupErrs := manager.EventChan()
+
+	events := make(chan MemoryEvents)
+	errs := make(chan error, 1)
+
+	go forwardMemoryEvents(cgroupEvents, cgroupErrs, events, errs)
+
+	return events, errs, nil
+}
+
+// forwardMemoryEvents sends the memory event counts from the cgroup2 event
+// channels to events and errs, closing them once cgroupErrs is closed.
+func forwardMemoryEvents(
+	cgroupEvents <-chan cgroup2.Event,
+	cgroupErrs <-chan error,
+	events chan<- MemoryEvents,
+	errs chan<- error,
+) {
+	defer close(events)
+	defer close(errs)
+
+	for {
+		select {
+		case e := <-cgroupEvents:
+			events <- MemoryEvents{OOM: e.OOM, OOMKill: e.OOMKill}
+		case err, ok := <-cgroupErrs:
+			// The last event, e.g. the oom_kill of a container killed by the
+			// OOM killer, is buffered before cgroupErrs is closed once the
+			// cgroup is empty, so it mustn't be dropped.
+			select {
+			case e := <-cgroupEvents:
+				events <- MemoryEvents{OOM: e.OOM, OOMKill: e.OOMKill}
+			default:
+			}
+
+			if ok && err != nil {
+				errs <- err
+			}
+
+			return
+		}
+	}
+}
+
+// convertCgroupStats converts the given cgroup2 metrics to CgroupStats.
+func convertCgroupStats(metrics *stats.Metrics) *CgroupStats {
+	s := &CgroupStats{Hugetlb: make(map[string]HugetlbStats)}
+
+	if cpu := metrics.GetCPU(); cpu != nil {
+		s.CPU = CPUStats{
+			Usage: CPUUsage{
+				Total:  cpu.UsageUsec * 1000,
+				Kernel: cpu.SystemUsec * 1000,
+				User:   cpu.UserUsec * 1000,
+			},
+			Throttling: Throttling{
+				Periods:          cpu.NrPeriods,
+				ThrottledPeriods: cpu.NrThrottled,
+				ThrottledTime:    cpu.ThrottledUsec * 1000,
+			},
+			PSI: convertPSIStats(cpu.PSI),
+		}
+	}
+
+	if memory := metrics.GetMemory(); memory != nil {
+		var failcnt uint64
+		if events := metrics.GetMemoryEvents(); events != nil {
+			failcnt = events.Max
+		}
+
+		s.Memory = MemoryStats{
+			Cache: memory.File,
+			Usage: MemoryEntry{
+				Limit:   memory.UsageLimit,
+				Usage:   memory.Usage,
+				Max:     memory.MaxUsage,
+				Failcnt: failcnt,
+			},
+			Swap: MemoryEntry{
+				Limit: addLimits(memory.UsageLimit, memory.SwapLimit),
+				Usage: memory.Usage + memory.SwapUsage,
+			},
+			Raw: map[string]uint64{
+				"anon":               memory.Anon,
+				"file":               memory.File,
+				"kernel_stack":       memory.KernelStack,
+				"slab":               memory.Slab,
+				"sock":               memory.Sock,
+				"shmem":              memory.Shmem,
+				"file_mapped":        memory.FileMapped,
+				"file_dirty":         memory.FileDirty,
+				"file_writeback":     memory.FileWriteback,
+				"anon_thp":           memory.AnonThp,
+				"inactive_anon":      memory.InactiveAnon,
+				"active_anon":        memory.ActiveAnon,
+				"inactive_file":      memory.InactiveFile,
+				"active_file":        memory.ActiveFile,
+				"unevictable":        memory.Unevictable,
+				"slab_reclaimable":   memory.SlabReclaimable,
+				"slab_unreclaimable": memory.SlabUnreclaimable,
+				"pgfault":            memory.Pgfault,
+				"pgmajfault":         memory.Pgmajfault,
+			},
+			PSI: convertPSIStats(memory.PSI),
+		}
+	}
+
+	if pids := metrics.GetPids(); pids != nil {
+		s.Pids = PidsStats{Current: pids.Current, Limit: pids.Limit}
+	}
+
+	if io := metrics.GetIo(); io != nil {
+		for _, e := range io.Usage {
+			s.Blkio.IoServiceBytesRecursive = append(
+				s.Blkio.IoServiceBytesRecursive,
+				BlkioEntry{Major: e.Major, Minor: e.Minor, Op: "Read", Value: e.Rbytes},
+				BlkioEntry{Major: e.Major, Minor: e.Minor, Op: "Write", Value: e.Wbytes},
+			)
+			s.Blkio.IoServicedRecursive = append(
+				s.Blkio.IoServicedRecursive,
+				BlkioEntry{Major: e.Major, Minor: e.Minor, Op: "Read", Value: e.Rios},
+				BlkioEntry{Major: e.Major, Minor: e.Minor, Op: "Write", Value: e.Wios},
+			)
+		}
+
+		s.Blkio.PSI = convertPSIStats(io.PSI)
+	}
+
+	for _, h := range metrics.GetHugetlb() {
+		s.Hugetlb[h.Pagesize] = HugetlbStats{
+			Usage:   h.Current,
+			Max:     h.Max,
+			Failcnt: h.Failcnt,
+		}
+	}
+
+	return s
+}
+
+func convertPSIStats(psi *stats.PSIStats) *PSIStats {
+	if psi == nil {
+		return nil
+	}
+
+	convert := func(d *stats.PSIData) PSIData {
+		if d == nil {
+			return PSIData{}
+		}
+
+		return PSIData{Avg10: d.Avg10, Avg60: d.Avg60, Avg300: d.Avg300, Total: d.Total}
+	}
+
+	return &PSIStats{Some: convert(psi.Some), Full: convert(psi.Full)}
+}
+
+// addLimits adds the given limits, where the max uint64 means no limit.
+func addLimits(a, b uint64) uint64 {
+	if a == math.MaxUint64 || b == math.MaxUint64 || a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+
+	return a + b
+}
@@ -0,0 +1,174 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containerd/cgroups/v3/cgroup2"
+	systemdDbus "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	// systemdUnitTimeout is how long to wait for the systemd user instance to
+	// start or stop a unit.
+	systemdUnitTimeout = 30 * time.Second
+
+	// systemdUserConnectTimeout is how long to wait to connect to the systemd
+	// user instance when checking it's available.
+	systemdUserConnectTimeout = 5 * time.Second
+)
+
+// systemdUserManagerCgroup returns the cgroup of the systemd user instance of
+// the current user, i.e. the root of its delegated subtree.
+func systemdUserManagerCgroup() string {
+	uid := os.Geteuid()
+	return fmt.Sprintf("/user.slice/user-%d.slice/user@%d.service", uid, uid)
+}
+
+// HasSystemdUserInstance checks whether the systemd user instance of the
+// current user is running, with its delegated subtree, and reachable over
+// D-Bus.
+func HasSystemdUserInstance() bool {
+	path := filepath.Join(cgroupRoot, systemdUserManagerCgroup())
+	if _, err := os.Stat(path); err != nil {
+		slog.Debug("no systemd user instance cgroup", "path", path, "err", err)
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), systemdUserConnectTimeout)
+	defer cancel()
+
+	conn, err := systemdDbus.NewUserConnectionContext(ctx)
+	if err != nil {
+		slog.Debug("failed to connect to systemd user instance", "err", err)
+		return false
+	}
+	conn.Close()
+
+	return true
+}
+
+// createSystemdUserCgroup creates the given cgroup as a transient unit of the
+// systemd user instance, placing the process specified by containerPID into
+// it. The unit is delegated, so the resources are written to the cgroup
+// directly rather than set as unit properties.
+func createSystemdUserCgroup(
+	cg Cgroup,
+	containerPID int,
+	resources *cgroup2.Resources,
+) error {
+	slice, group := systemdSliceAndGroup(cg)
+
+	if err := validateCgroupsSliceGroup(slice, group); err != nil {
+		return fmt.Errorf("validate cgroup slice and group: %w", err)
+	}
+
+	properties := []systemdDbus.Property{
+		systemdDbus.PropDescription("anocir container " + cg.ContainerID),
+		systemdDbus.PropPids(uint32(containerPID)),
+		newSystemdProperty("DefaultDependencies", false),
+		newSystemdProperty("Delegate", true),
+		newSystemdProperty("MemoryAccounting", true),
+		newSystemdProperty("CPUAccounting", true),
+		newSystemdProperty("IOAccounting", true),
+		newSystemdProperty("TasksAccounting", true),
+	}
+
+	// The root slice is "/" in the cgroup path, but -.slice to systemd.
+	if slice == "/" {
+		slice = "-.slice"
+	}
+
+	if strings.HasSuffix(group, ".slice") {
+		properties = append(properties, systemdDbus.PropWants(slice))
+	} else {
+		properties = append(properties, systemdDbus.PropSlice(slice))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), systemdUnitTimeout)
+	defer cancel()
+
+	conn, err := systemdDbus.NewUserConnectionContext(ctx)
+	if err != nil {
+		return fmt.Errorf("connect to systemd user instance: %w", err)
+	}
+	defer conn.Close()
+
+	ch := make(chan string, 1)
+	if _, err := conn.StartTransientUnitContext(ctx, group, "replace", properties, ch); err != nil {
+		return fmt.Errorf("start transient unit %s: %w", group, err)
+	}
+
+	if err := waitSystemdJob(ctx, ch); err != nil {
+		// Reset the unit so a retry with the same name isn't rejected.
+		if err := conn.ResetFailedUnitContext(context.Background(), group); err != nil {
+			slog.Debug("failed to reset failed unit", "unit", group, "err", err)
+		}
+
+		return fmt.Errorf("start transient unit %s: %w", group, err)
+	}
+
+	manager, err := loadCgroupManager(cg)
+	if err != nil {
+		return fmt.Errorf("load cgroup manager: %w", err)
+	}
+
+	if err := manager.Update(resources); err != nil {
+		return fmt.Errorf("update cgroup resources: %w", err)
+	}
+
+	return nil
+}
+
+// stopSystemdUserUnit stops the transient unit of the given cgroup in the
+// systemd user instance, which removes the cgroup.
+func stopSystemdUserUnit(cg Cgroup) error {
+	_, group := systemdSliceAndGroup(cg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), systemdUnitTimeout)
+	defer cancel()
+
+	conn, err := systemdDbus.NewUserConnectionContext(ctx)
+	if err != nil {
+		return fmt.Errorf("connect to systemd user instance: %w", err)
+	}
+	defer conn.Close()
+
+	ch := make(chan string, 1)
+	if _, err := conn.StopUnitContext(ctx, group, "replace", ch); err != nil {
+		return fmt.Errorf("stop unit %s: %w", group, err)
+	}
+
+	if err := waitSystemdJob(ctx, ch); err != nil {
+		return fmt.Errorf("stop unit %s: %w", group, err)
+	}
+
+	return nil
+}
+
+// waitSystemdJob waits for the result of a systemd job on ch.
+func waitSystemdJob(ctx context.Context, ch <-chan string) error {
+	select {
+	case result := <-ch:
+		if result != "done" {
+			return fmt.Errorf("job result: %s", result)
+		}
+
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for job: %w", ctx.Err())
+	}
+}
+
+func newSystemdProperty(name string, value any) systemdDbus.Property {
+	return systemdDbus.Property{
+		Name:  name,
+		Value: dbus.MakeVariant(value),
+	}
+}
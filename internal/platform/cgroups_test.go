@@ -1,9 +1,20 @@
 package platform
 
 import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/containerd/cgroups/v3/cgroup2"
+	"github.com/containerd/cgroups/v3/cgroup2/stats"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBuildSystemdCGroupSliceAndGroup(t *testing.T) {
@@ -108,6 +119,7 @@ func TestBuildSystemdCGroupSliceAndGroup(t *testing.T) {
 			slice, scope := buildSystemdCGroupSliceAndGroup(
 				data.cgroupsPath,
 				data.containerID,
+				defaultSystemdSlice,
 			)
 
 			assert.Equal(t, data.systemdCgroupSlice, slice)
@@ -115,3 +127,821 @@ func TestBuildSystemdCGroupSliceAndGroup(t *testing.T) {
 		})
 	}
 }
+
+func TestFindContainerCgroups(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+
+	for _, dir := range []string{
+		"system.slice/anocir-busybox.scope",
+		"system.slice/other.scope",
+		"anocir-toplevel.scope",
+		"user.slice/user-1000.slice/anocir-alpine.scope/nested",
+		"anocir/debian/nested",
+		"user.slice/user-4242.slice/user@4242.service/user.slice/anocir-other-user.scope",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0o755))
+	}
+
+	require.NoError(t, os.WriteFile(
+		filepath.Join(root, "system.slice/anocir-busybox.scope/cgroup.procs"),
+		[]byte("1\n2\n"),
+		0o644,
+	))
+
+	cgroups, err := findContainerCgroups(root)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []ContainerCgroup{
+		{
+			Path: filepath.Join(root, "system.slice/anocir-busybox.scope"),
+			Cgroup: Cgroup{
+				Driver:      SystemdDriver,
+				CgroupsPath: "system.slice:anocir:busybox",
+				ContainerID: "busybox",
+			},
+			Processes: 2,
+		},
+		{
+			Path: filepath.Join(root, "user.slice/user-1000.slice/anocir-alpine.scope"),
+			Cgroup: Cgroup{
+				Driver:      SystemdDriver,
+				CgroupsPath: "user-1000.slice:anocir:alpine",
+				ContainerID: "alpine",
+			},
+			Processes: 0,
+		},
+		{
+			Path: filepath.Join(root, "anocir-toplevel.scope"),
+			Cgroup: Cgroup{
+				Driver:      SystemdDriver,
+				CgroupsPath: "-:anocir:toplevel",
+				ContainerID: "toplevel",
+			},
+			Processes: 0,
+		},
+		{
+			Path: filepath.Join(root, "anocir/debian"),
+			Cgroup: Cgroup{
+				Driver:      CgroupfsDriver,
+				CgroupsPath: "/anocir/debian",
+				ContainerID: "debian",
+			},
+			Processes: 0,
+		},
+	}, cgroups)
+
+	// The cgroups found can be acted on, e.g. by gc.
+	for _, cg := range cgroups {
+		path, err := cgroupPath(cg.Cgroup)
+		require.NoError(t, err)
+		assert.Equal(t, strings.TrimPrefix(cg.Path, root), strings.TrimPrefix(path, cgroupRoot))
+	}
+}
+
+func TestGetCgroupInfo(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		cgroup Cgroup
+		info   *CgroupInfo
+	}{
+		"test default systemd cgroup": {
+			cgroup: Cgroup{Driver: SystemdDriver, ContainerID: "busybox"},
+			info: &CgroupInfo{
+				Driver: SystemdDriver,
+				Slice:  "system.slice",
+				Scope:  "anocir-busybox.scope",
+				Path:   "/sys/fs/cgroup/system.slice/anocir-busybox.scope",
+			},
+		},
+		"test nested slice": {
+			cgroup: Cgroup{
+				Driver:      SystemdDriver,
+				CgroupsPath: "user-1000.slice:anocir:alpine",
+				ContainerID: "alpine",
+			},
+			info: &CgroupInfo{
+				Driver: SystemdDriver,
+				Slice:  "user-1000.slice",
+				Scope:  "anocir-alpine.scope",
+				Path:   "/sys/fs/cgroup/user.slice/user-1000.slice/anocir-alpine.scope",
+			},
+		},
+		"test container slice": {
+			cgroup: Cgroup{
+				Driver:      SystemdDriver,
+				CgroupsPath: "machine.slice::pod-abc.slice",
+				ContainerID: "alpine",
+			},
+			info: &CgroupInfo{
+				Driver: SystemdDriver,
+				Slice:  "machine.slice",
+				Scope:  "pod-abc.slice",
+				Path:   "/sys/fs/cgroup/machine.slice/pod.slice/pod-abc.slice",
+			},
+		},
+		"test default systemd user cgroup": {
+			cgroup: Cgroup{Driver: SystemdUserDriver, ContainerID: "busybox"},
+			info: &CgroupInfo{
+				Driver: SystemdUserDriver,
+				Slice:  "user.slice",
+				Scope:  "anocir-busybox.scope",
+				Path:   "/sys/fs/cgroup" + systemdUserManagerCgroup() + "/user.slice/anocir-busybox.scope",
+			},
+		},
+		"test default cgroupfs cgroup": {
+			cgroup: Cgroup{Driver: CgroupfsDriver, ContainerID: "busybox"},
+			info: &CgroupInfo{
+				Driver: CgroupfsDriver,
+				Path:   "/sys/fs/cgroup/anocir/busybox",
+			},
+		},
+		"test cgroupfs cgroupsPath": {
+			cgroup: Cgroup{
+				Driver:      CgroupfsDriver,
+				CgroupsPath: "/mygroup/ctr1",
+				ContainerID: "busybox",
+			},
+			info: &CgroupInfo{
+				Driver: CgroupfsDriver,
+				Path:   "/sys/fs/cgroup/mygroup/ctr1",
+			},
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			info, err := GetCgroupInfo(data.cgroup)
+			require.NoError(t, err)
+			assert.Equal(t, data.info, info)
+		})
+	}
+}
+
+func TestBuildCgroupfsGroup(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		cgroupsPath string
+		containerID string
+		group       string
+		assertErr   assert.ErrorAssertionFunc
+	}{
+		"test empty cgroupsPath": {
+			cgroupsPath: "",
+			containerID: "busybox",
+			group:       "/anocir/busybox",
+			assertErr:   assert.NoError,
+		},
+		"test absolute cgroupsPath": {
+			cgroupsPath: "/mygroup/ctr1",
+			containerID: "busybox",
+			group:       "/mygroup/ctr1",
+			assertErr:   assert.NoError,
+		},
+		"test relative cgroupsPath": {
+			cgroupsPath: "mygroup/ctr1/",
+			containerID: "busybox",
+			group:       "/mygroup/ctr1",
+			assertErr:   assert.NoError,
+		},
+		"test systemd cgroupsPath": {
+			cgroupsPath: "system.slice:anocir:busybox",
+			containerID: "busybox",
+			group:       "",
+			assertErr:   assert.Error,
+		},
+		"test directory traversal": {
+			cgroupsPath: "/mygroup/../../ctr1",
+			containerID: "busybox",
+			group:       "",
+			assertErr:   assert.Error,
+		},
+		"test cgroup root": {
+			cgroupsPath: "/",
+			containerID: "busybox",
+			group:       "",
+			assertErr:   assert.Error,
+		},
+		"test empty cgroupsPath and container ID": {
+			cgroupsPath: "",
+			containerID: "",
+			group:       "",
+			assertErr:   assert.Error,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			group, err := buildCgroupfsGroup(data.cgroupsPath, data.containerID)
+			data.assertErr(t, err)
+			assert.Equal(t, data.group, group)
+		})
+	}
+}
+
+func TestForwardMemoryEvents(t *testing.T) {
+	t.Parallel()
+
+	// As cgroup2 EventChan does when the cgroup empties, the last event is
+	// buffered and then the error channel closed.
+	cgroupEvents := make(chan cgroup2.Event, 1)
+	cgroupErrs := make(chan error, 1)
+	cgroupEvents <- cgroup2.Event{OOM: 1, OOMKill: 1}
+	close(cgroupErrs)
+
+	events := make(chan MemoryEvents)
+	errs := make(chan error, 1)
+
+	go forwardMemoryEvents(cgroupEvents, cgroupErrs, events, errs)
+
+	var got []MemoryEvents
+	for e := range events {
+		got = append(got, e)
+	}
+
+	// Either channel may be selected first, but the event is never dropped.
+	assert.Contains(t, got, MemoryEvents{OOM: 1, OOMKill: 1})
+	assert.NoError(t, <-errs)
+}
+
+func TestConvertCgroupStats(t *testing.T) {
+	t.Parallel()
+
+	s := convertCgroupStats(&stats.Metrics{
+		CPU: &stats.CPUStat{
+			UsageUsec:     300,
+			UserUsec:      200,
+			SystemUsec:    100,
+			NrPeriods:     10,
+			NrThrottled:   2,
+			ThrottledUsec: 50,
+		},
+		Memory: &stats.MemoryStat{
+			File:       4096,
+			Usage:      8192,
+			UsageLimit: 16384,
+			SwapUsage:  1024,
+			SwapLimit:  math.MaxUint64,
+		},
+		MemoryEvents: &stats.MemoryEvents{Max: 3},
+		Pids:         &stats.PidsStat{Current: 2, Limit: 100},
+		Io: &stats.IOStat{
+			Usage: []*stats.IOEntry{
+				{Major: 8, Minor: 0, Rbytes: 512, Wbytes: 1024, Rios: 1, Wios: 2},
+			},
+			PSI: &stats.PSIStats{Some: &stats.PSIData{Avg10: 1.5, Total: 10}},
+		},
+		Hugetlb: []*stats.HugeTlbStat{
+			{Pagesize: "2MB", Current: 2097152, Failcnt: 1},
+		},
+	})
+
+	assert.Equal(t, CPUUsage{Total: 300000, Kernel: 100000, User: 200000}, s.CPU.Usage)
+	assert.Equal(t, Throttling{Periods: 10, ThrottledPeriods: 2, ThrottledTime: 50000}, s.CPU.Throttling)
+
+	assert.Equal(t, uint64(4096), s.Memory.Cache)
+	assert.Equal(t, MemoryEntry{Limit: 16384, Usage: 8192, Failcnt: 3}, s.Memory.Usage)
+	assert.Equal(t, MemoryEntry{Limit: math.MaxUint64, Usage: 9216}, s.Memory.Swap)
+
+	assert.Equal(t, PidsStats{Current: 2, Limit: 100}, s.Pids)
+
+	assert.Equal(t, []BlkioEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 512},
+		{Major: 8, Minor: 0, Op: "Write", Value: 1024},
+	}, s.Blkio.IoServiceBytesRecursive)
+	assert.Equal(t, []BlkioEntry{
+		{Major: 8, Minor: 0, Op: "Read", Value: 1},
+		{Major: 8, Minor: 0, Op: "Write", Value: 2},
+	}, s.Blkio.IoServicedRecursive)
+	assert.Equal(t, &PSIStats{Some: PSIData{Avg10: 1.5, Total: 10}}, s.Blkio.PSI)
+
+	assert.Equal(t, map[string]HugetlbStats{
+		"2MB": {Usage: 2097152, Failcnt: 1},
+	}, s.Hugetlb)
+}
+
+func TestNormaliseCPUList(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		list string
+		want string
+	}{
+		"test single":         {list: "0", want: "0"},
+		"test range":          {list: "0-3", want: "0-3"},
+		"test list to range":  {list: "3,0,1,2", want: "0-3"},
+		"test mixed":          {list: "5,0-1,7-8,2\n", want: "0-2,5,7-8"},
+		"test duplicates":     {list: "0-2,1", want: "0-2"},
+		"test empty":          {list: "", want: ""},
+		"test invalid as is":  {list: "a-b", want: "a-b"},
+		"test reversed range": {list: "3-1", want: "3-1"},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, data.want, normaliseCPUList(data.list))
+		})
+	}
+}
+
+func TestExpectedCgroupValues(t *testing.T) {
+	t.Parallel()
+
+	unlimited := int64(-1)
+	limit := int64(1 << 30)
+	period := uint64(100000)
+
+	// Limits of -1 are applied as unlimited, which the kernel reports as max.
+	assert.Equal(t, map[string]string{
+		"memory.max":      "max",
+		"memory.swap.max": "max",
+		"cpu.max":         "max 100000",
+	}, expectedCgroupValues(&specs.LinuxResources{
+		Memory: &specs.LinuxMemory{Limit: &unlimited, Swap: &unlimited},
+		CPU:    &specs.LinuxCPU{Quota: &unlimited, Period: &period},
+	}))
+
+	assert.Equal(t, map[string]string{
+		"memory.max":      "1073741824",
+		"memory.swap.max": "max",
+	}, expectedCgroupValues(&specs.LinuxResources{
+		Memory: &specs.LinuxMemory{Limit: &limit, Swap: &unlimited},
+	}))
+}
+
+func TestCheckCgroupResources(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	for file, value := range map[string]string{
+		"memory.max":      "1073741824\n",
+		"memory.swap.max": "max\n",
+		"cpu.max":         "50000 100000\n",
+		"cpuset.cpus":     "0-3\n",
+		"pids.max":        "50\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(value), 0o644))
+	}
+
+	limit := int64(1 << 30)
+	swap := int64(-1)
+	quota := int64(50000)
+	period := uint64(100000)
+	pids := int64(100)
+	reservation := int64(1 << 20)
+
+	drift, err := checkCgroupResources(dir, &specs.LinuxResources{
+		Memory: &specs.LinuxMemory{Limit: &limit, Swap: &swap, Reservation: &reservation},
+		CPU:    &specs.LinuxCPU{Quota: &quota, Period: &period, Cpus: "0,1,2,3"},
+		Pids:   &specs.LinuxPids{Limit: &pids},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []ResourceDrift{
+		{File: "memory.low", Expected: "1048576", Actual: ""},
+		{File: "pids.max", Expected: "100", Actual: "50"},
+	}, drift)
+}
+
+func TestApplyUnified(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		unified map[string]string
+		applied []string
+		wantErr bool
+	}{
+		"test enabled controllers": {
+			unified: map[string]string{"memory.high": "1073741824", "pids.max": "10"},
+			applied: []string{"memory.high", "pids.max"},
+		},
+		"test core interface file": {
+			unified: map[string]string{"cgroup.max.depth": "2"},
+			applied: []string{"cgroup.max.depth"},
+		},
+		"test controller not enabled": {
+			unified: map[string]string{"memory.high": "1024", "io.latency": "8:0 target=10"},
+			wantErr: true,
+		},
+		"test path traversal": {
+			unified: map[string]string{"../memory.high": "1024"},
+			wantErr: true,
+		},
+		"test path separator": {
+			unified: map[string]string{"memory.high/x": "1024"},
+			wantErr: true,
+		},
+		"test no controller": {
+			unified: map[string]string{"memory": "1024"},
+			wantErr: true,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(
+				filepath.Join(dir, "cgroup.controllers"),
+				[]byte("cpu memory pids\n"),
+				0o644,
+			))
+
+			applied, err := applyUnified(dir, data.unified)
+			if data.wantErr {
+				assert.Error(t, err)
+
+				// Nothing is written when any key is invalid.
+				entries, err := os.ReadDir(dir)
+				require.NoError(t, err)
+				assert.Len(t, entries, 1)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, data.applied, applied)
+
+			for key, value := range data.unified {
+				written, err := os.ReadFile(filepath.Join(dir, key))
+				require.NoError(t, err)
+				assert.Equal(t, value, string(written))
+			}
+		})
+	}
+}
+
+func TestDeviceRules(t *testing.T) {
+	t.Parallel()
+
+	deny := specs.LinuxDeviceCgroup{Allow: false, Access: "rwm"}
+
+	t.Run("test no rules", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Nil(t, DeviceRules(nil, []specs.LinuxDevice{
+			{Type: CharDevice, Path: "/dev/fuse", Major: 10, Minor: 229},
+		}))
+	})
+
+	t.Run("test rules allow devices", func(t *testing.T) {
+		t.Parallel()
+
+		rules := DeviceRules([]specs.LinuxDeviceCgroup{deny}, []specs.LinuxDevice{
+			{Type: UnbufferedCharDevice, Path: "/dev/fuse", Major: 10, Minor: 229},
+			{Type: FifoDevice, Path: "/dev/fifo"},
+		})
+
+		require.Len(t, rules, 1+len(defaultDevices)+1+len(allowedDeviceRules))
+
+		// The given rules come first, so the allow rules take precedence.
+		assert.Equal(t, AllDevices, rules[0].Type)
+		assert.Equal(t, int64(-1), *rules[0].Major)
+		assert.Equal(t, int64(-1), *rules[0].Minor)
+		assert.False(t, rules[0].Allow)
+
+		fuse := rules[len(defaultDevices)+1]
+		assert.True(t, fuse.Allow)
+		assert.Equal(t, CharDevice, fuse.Type)
+		assert.Equal(t, int64(10), *fuse.Major)
+		assert.Equal(t, int64(229), *fuse.Minor)
+		assert.Equal(t, "rwm", fuse.Access)
+	})
+
+	t.Run("test rules compile", func(t *testing.T) {
+		t.Parallel()
+
+		spec, err := deviceFilterSpec(DeviceRules(
+			[]specs.LinuxDeviceCgroup{deny},
+			nil,
+		))
+		require.NoError(t, err)
+
+		tag, err := spec.Tag()
+		require.NoError(t, err)
+		assert.Len(t, tag, 16)
+	})
+}
+
+func TestValidateExecCgroupPath(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		path    string
+		wantErr bool
+	}{
+		"test name":     {path: "exec-1"},
+		"test nested":   {path: "debug/backup"},
+		"test absolute": {path: "/exec", wantErr: true},
+		"test parent":   {path: "../exec", wantErr: true},
+		"test empty":    {path: "", wantErr: true},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateExecCgroupPath(data.path)
+			if data.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckExecControllers(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		controllers []string
+		wantErr     bool
+	}{
+		"test no controllers":       {controllers: nil},
+		"test threaded controllers": {controllers: []string{"cpu", "cpuset", "pids"}},
+		"test domain controller":    {controllers: []string{"memory"}, wantErr: true},
+		"test domain and threaded":  {controllers: []string{"cpu", "io"}, wantErr: true},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkExecControllers(data.controllers)
+			if data.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFindStaleExecCgroups(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	for name, procs := range map[string]string{
+		"exec-1073741824":                   "",
+		"exec-1073741825":                   "1234\n",
+		fmt.Sprintf("exec-%d", os.Getpid()): "",
+		"debug":                             "",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0o755))
+		require.NoError(t, os.WriteFile(
+			filepath.Join(dir, name, "cgroup.procs"),
+			[]byte(procs),
+			0o644,
+		))
+	}
+
+	stale, err := FindStaleExecCgroups(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"exec-1073741824"}, stale)
+
+	stale, err = FindStaleExecCgroups(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, stale)
+}
+
+func TestCountCgroupProcesses(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "exec-1"), 0o755))
+
+	for path, procs := range map[string]string{
+		"cgroup.procs":        "1\n2\n",
+		"exec-1/cgroup.procs": "3\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(procs), 0o644))
+	}
+
+	count, err := countCgroupProcesses(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestToCgroupResources(t *testing.T) {
+	t.Parallel()
+
+	ptr := func(v int64) *int64 { return &v }
+	unlimited := strconv.FormatInt(cgroupMemoryUnlimited, 10)
+
+	period := uint64(100000)
+
+	scenarios := map[string]struct {
+		memory *specs.LinuxMemory
+		cpu    *specs.LinuxCPU
+		want   map[string]string
+	}{
+		"test limits": {
+			memory: &specs.LinuxMemory{Limit: ptr(1 << 30), Reservation: ptr(1 << 29), Swap: ptr(2 << 30)},
+			want: map[string]string{
+				"memory.max":      "1073741824",
+				"memory.low":      "536870912",
+				"memory.swap.max": "1073741824",
+			},
+		},
+		"test unlimited memory": {
+			memory: &specs.LinuxMemory{Limit: ptr(-1)},
+			want:   map[string]string{"memory.max": unlimited},
+		},
+		"test unlimited swap with memory limit": {
+			memory: &specs.LinuxMemory{Limit: ptr(1 << 30), Swap: ptr(-1)},
+			want: map[string]string{
+				"memory.max":      "1073741824",
+				"memory.swap.max": unlimited,
+			},
+		},
+		"test swap without memory limit": {
+			memory: &specs.LinuxMemory{Swap: ptr(1 << 30)},
+			want:   map[string]string{"memory.swap.max": "1073741824"},
+		},
+		"test unlimited cpu quota": {
+			cpu:  &specs.LinuxCPU{Quota: ptr(-1), Period: &period},
+			want: map[string]string{"cpu.max": "max 100000"},
+		},
+		"test cpu quota without period": {
+			cpu:  &specs.LinuxCPU{Quota: ptr(50000)},
+			want: map[string]string{"cpu.max": "50000 100000"},
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			root := t.TempDir()
+			require.NoError(t, os.Mkdir(filepath.Join(root, "test"), 0o755))
+
+			manager, err := cgroup2.Load("/test", cgroup2.WithMountpoint(root))
+			require.NoError(t, err)
+
+			require.NoError(t, manager.Update(
+				toCgroupResources(&specs.LinuxResources{Memory: data.memory, CPU: data.cpu}),
+			))
+
+			got := make(map[string]string)
+			for _, file := range []string{"memory.max", "memory.low", "memory.swap.max", "cpu.max"} {
+				if data, err := os.ReadFile(filepath.Join(root, "test", file)); err == nil {
+					got[file] = string(data)
+				}
+			}
+
+			assert.Equal(t, data.want, got)
+		})
+	}
+}
+
+func TestNoCgroupDriver(t *testing.T) {
+	t.Parallel()
+
+	cg := Cgroup{Driver: NoCgroupDriver, ContainerID: "busybox"}
+
+	unified, err := CreateCgroup(cg, os.Getpid(), &specs.LinuxResources{})
+	assert.NoError(t, err)
+	assert.Empty(t, unified)
+
+	assert.NoError(t, DeleteCgroup(cg))
+
+	_, err = GetCgroupProcesses(cg)
+	assert.ErrorIs(t, err, ErrNoCgroup)
+
+	assert.ErrorIs(t, FreezeCgroup(cg, time.Second), ErrNoCgroup)
+}
+
+func TestReclaimMemory(t *testing.T) {
+	t.Parallel()
+
+	swappiness := uint64(0)
+	tooHigh := uint64(MaxSwappiness + 1)
+
+	scenarios := map[string]struct {
+		amount     uint64
+		swappiness *uint64
+		want       string
+		wantErr    bool
+	}{
+		"test amount":     {amount: 1 << 20, want: "1048576"},
+		"test swappiness": {amount: 4096, swappiness: &swappiness, want: "4096 swappiness=0"},
+		"test invalid swappiness": {
+			amount:     4096,
+			swappiness: &tooHigh,
+			wantErr:    true,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+
+			complete, err := reclaimMemory(dir, data.amount, data.swappiness)
+			if data.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, complete)
+
+			written, err := os.ReadFile(filepath.Join(dir, "memory.reclaim"))
+			require.NoError(t, err)
+			assert.Equal(t, data.want, string(written))
+		})
+	}
+}
+
+func TestFreezeCgroup(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		events     string
+		wantFreeze string
+		wantErr    error
+	}{
+		"test frozen": {
+			events:     "populated 1\nfrozen 1\n",
+			wantFreeze: "1",
+		},
+		"test not frozen in time is thawed": {
+			events:     "populated 1\nfrozen 0\n",
+			wantFreeze: "0",
+			wantErr:    ErrFreezeTimeout,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(
+				filepath.Join(dir, "cgroup.events"),
+				[]byte(data.events),
+				0o644,
+			))
+
+			err := freezeCgroup(dir, 20*time.Millisecond)
+			if data.wantErr != nil {
+				assert.ErrorIs(t, err, data.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			written, err := os.ReadFile(filepath.Join(dir, "cgroup.freeze"))
+			require.NoError(t, err)
+			assert.Equal(t, data.wantFreeze, string(written))
+		})
+	}
+}
+
+func TestIsCgroupFrozen(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		events  string
+		frozen  bool
+		wantErr bool
+	}{
+		"test frozen":          {events: "populated 1\nfrozen 1\n", frozen: true},
+		"test not frozen":      {events: "populated 1\nfrozen 0\n", frozen: false},
+		"test no frozen state": {events: "populated 1\n", wantErr: true},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(
+				filepath.Join(dir, "cgroup.events"),
+				[]byte(data.events),
+				0o644,
+			))
+
+			frozen, err := isCgroupFrozen(dir)
+			if data.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, data.frozen, frozen)
+		})
+	}
+}
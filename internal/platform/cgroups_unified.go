@@ -0,0 +1,92 @@
+package platform
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// coreCgroupController is the prefix of the interface files of the cgroup
+// core, e.g. cgroup.max.depth, which exist whichever controllers are enabled.
+const coreCgroupController = "cgroup"
+
+// applyCgroupUnified writes the given unified cgroup v2 settings to the
+// interface files of the given cgroup, returning the keys that were written
+// in order.
+func applyCgroupUnified(cg Cgroup, unified map[string]string) ([]string, error) {
+	if len(unified) == 0 {
+		return nil, nil
+	}
+
+	path, err := cgroupPath(cg)
+	if err != nil {
+		return nil, fmt.Errorf("get cgroup path: %w", err)
+	}
+
+	return applyUnified(path, unified)
+}
+
+func applyUnified(path string, unified map[string]string) ([]string, error) {
+	if err := validateUnifiedKeys(unified); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(path, "cgroup.controllers"))
+	if err != nil {
+		return nil, fmt.Errorf("read enabled controllers: %w", err)
+	}
+
+	controllers := strings.Fields(string(data))
+
+	keys := slices.Sorted(maps.Keys(unified))
+
+	// Check every key before writing any, so an invalid key doesn't leave the
+	// settings partially applied.
+	for _, key := range keys {
+		controller, _, _ := strings.Cut(key, ".")
+
+		if controller != coreCgroupController &&
+			!slices.Contains(controllers, controller) {
+			return nil, fmt.Errorf(
+				"unified key %s: controller %s is not enabled for the cgroup",
+				key, controller,
+			)
+		}
+	}
+
+	applied := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if err := os.WriteFile(
+			filepath.Join(path, key),
+			[]byte(unified[key]),
+			0o644,
+		); err != nil {
+			return applied, fmt.Errorf("write unified key %s: %w", key, err)
+		}
+
+		applied = append(applied, key)
+	}
+
+	return applied, nil
+}
+
+// validateUnifiedKeys checks the given unified keys are the names of cgroup
+// interface files, i.e. <controller>.<name>, and not paths.
+func validateUnifiedKeys(unified map[string]string) error {
+	for key := range unified {
+		controller, name, ok := strings.Cut(key, ".")
+
+		if !ok ||
+			controller == "" ||
+			name == "" ||
+			strings.ContainsAny(key, "/\x00") ||
+			strings.Contains(key, "..") {
+			return fmt.Errorf("invalid unified key: %q", key)
+		}
+	}
+
+	return nil
+}
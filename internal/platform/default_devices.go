@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -159,6 +160,16 @@ func CreateDeviceNodes(devices []specs.LinuxDevice, rootfs string) error {
 			deviceType[d.Type],
 			int(unix.Mkdev(uint32(d.Major), uint32(d.Minor))),
 		); err != nil {
+			// Creating device nodes isn't permitted in a user namespace, so
+			// bind mount the host device instead.
+			if errors.Is(err, unix.EPERM) {
+				if err := bindMountDevice(d.Path, absPath); err != nil {
+					return fmt.Errorf("bind mount device %s: %w", d.Path, err)
+				}
+
+				continue
+			}
+
 			return fmt.Errorf("mknod %s: %w", absPath, err)
 		}
 
@@ -177,3 +188,18 @@ func CreateDeviceNodes(devices []specs.LinuxDevice, rootfs string) error {
 
 	return nil
 }
+
+// bindMountDevice bind mounts the host device at path to target, creating
+// target if it doesn't exist.
+func bindMountDevice(path, target string) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_RDONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("create device mount target: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		slog.Warn("failed to close device mount target", "path", target, "err", err)
+	}
+
+	return BindMount(path, target, false)
+}
@@ -2,6 +2,7 @@ package platform
 
 import (
 	"fmt"
+	"os"
 	"slices"
 
 	"golang.org/x/sys/unix"
@@ -17,10 +18,14 @@ var validPropagationFlags = []uintptr{
 
 func mount(source, target, fstype string, flags uintptr, data string) error {
 	if err := unix.Mount(source, target, fstype, flags, data); err != nil {
-		return fmt.Errorf(
-			"mount %s to %s (type=%s, flags=%#x): %w",
-			source, target, fstype, flags, err,
-		)
+		return &os.PathError{
+			Op:   "mount",
+			Path: target,
+			Err: fmt.Errorf(
+				"from %s (type=%s, flags=%#x): %w",
+				source, fstype, flags, err,
+			),
+		}
 	}
 
 	return nil
@@ -0,0 +1,111 @@
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MountInfo is a mount read from /proc/<pid>/mountinfo.
+type MountInfo struct {
+	ID       int `json:"id"`
+	ParentID int `json:"parentId"`
+	// Root is the path of the directory in the filesystem that forms the root
+	// of the mount, e.g. the source of a bind mount.
+	Root       string `json:"root"`
+	MountPoint string `json:"mountPoint"`
+	Options    string `json:"options"`
+	// Propagation is the optional fields, e.g. shared:1 or master:2.
+	Propagation  []string `json:"propagation,omitempty"`
+	FSType       string   `json:"fsType"`
+	Source       string   `json:"source"`
+	SuperOptions string   `json:"superOptions"`
+}
+
+// GetMountInfo returns the mounts in the mount namespace of the process with
+// the given pid, as seen from its root.
+func GetMountInfo(pid int) ([]MountInfo, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/mountinfo", pid))
+	if err != nil {
+		return nil, fmt.Errorf("open mountinfo: %w", err)
+	}
+	defer f.Close()
+
+	return parseMountInfo(f)
+}
+
+func parseMountInfo(r io.Reader) ([]MountInfo, error) {
+	var mounts []MountInfo
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(scanner.Text())
+
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+
+		if sep == -1 || len(fields) < sep+4 {
+			return nil, fmt.Errorf("invalid mountinfo line: %q", scanner.Text())
+		}
+
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("parse mount id: %w", err)
+		}
+
+		parentID, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("parse mount parent id: %w", err)
+		}
+
+		mounts = append(mounts, MountInfo{
+			ID:           id,
+			ParentID:     parentID,
+			Root:         unescapeMountPath(fields[3]),
+			MountPoint:   unescapeMountPath(fields[4]),
+			Options:      fields[5],
+			Propagation:  fields[6:sep],
+			FSType:       fields[sep+1],
+			Source:       unescapeMountPath(fields[sep+2]),
+			SuperOptions: fields[sep+3],
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read mountinfo: %w", err)
+	}
+
+	return mounts, nil
+}
+
+// unescapeMountPath unescapes the octal escapes, e.g. \040 for a space, that
+// the kernel uses for whitespace and backslashes in mountinfo paths.
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+
+		b.WriteByte(path[i])
+	}
+
+	return b.String()
+}
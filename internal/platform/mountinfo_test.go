@@ -0,0 +1,75 @@
+package platform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		mountinfo string
+		mounts    []MountInfo
+		assertErr assert.ErrorAssertionFunc
+	}{
+		"test mounts with and without optional fields": {
+			mountinfo: "22 1 0:21 / / rw,relatime shared:1 - overlay overlay rw,lowerdir=/l\n" +
+				"36 22 98:0 /mnt\\040dir /data rw,noatime master:1 shared:2 - ext4 /dev/sda1 rw\n" +
+				"40 22 0:5 / /dev rw - tmpfs tmpfs rw,mode=755\n",
+			mounts: []MountInfo{
+				{
+					ID:           22,
+					ParentID:     1,
+					Root:         "/",
+					MountPoint:   "/",
+					Options:      "rw,relatime",
+					Propagation:  []string{"shared:1"},
+					FSType:       "overlay",
+					Source:       "overlay",
+					SuperOptions: "rw,lowerdir=/l",
+				},
+				{
+					ID:           36,
+					ParentID:     22,
+					Root:         "/mnt dir",
+					MountPoint:   "/data",
+					Options:      "rw,noatime",
+					Propagation:  []string{"master:1", "shared:2"},
+					FSType:       "ext4",
+					Source:       "/dev/sda1",
+					SuperOptions: "rw",
+				},
+				{
+					ID:           40,
+					ParentID:     22,
+					Root:         "/",
+					MountPoint:   "/dev",
+					Options:      "rw",
+					Propagation:  []string{},
+					FSType:       "tmpfs",
+					Source:       "tmpfs",
+					SuperOptions: "rw,mode=755",
+				},
+			},
+			assertErr: assert.NoError,
+		},
+		"test missing separator": {
+			mountinfo: "22 1 0:21 / / rw,relatime shared:1 overlay overlay rw\n",
+			mounts:    nil,
+			assertErr: assert.Error,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			mounts, err := parseMountInfo(strings.NewReader(data.mountinfo))
+			data.assertErr(t, err)
+			assert.Equal(t, data.mounts, mounts)
+		})
+	}
+}
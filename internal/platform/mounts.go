@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -120,7 +121,15 @@ func MountSpecMounts(mounts []specs.Mount, containerRootfs string) error {
 			uintptr(flags),
 			strings.Join(dataOptions, ","),
 		); err != nil {
-			return fmt.Errorf("mount spec mount: %w", err)
+			// Mounting sysfs isn't permitted in a user namespace without its own
+			// network namespace, so recursively bind mount the host /sys instead.
+			if m.Type != "sysfs" || !errors.Is(err, unix.EPERM) {
+				return fmt.Errorf("mount spec mount: %w", err)
+			}
+
+			if err := bindMountSys(dest, flags); err != nil {
+				return fmt.Errorf("bind mount sysfs: %w", err)
+			}
 		}
 
 		// Apply propagation after the initial mount. Skip for shared/rshared since the
@@ -142,6 +151,20 @@ func MountSpecMounts(mounts []specs.Mount, containerRootfs string) error {
 	return nil
 }
 
+// bindMountSys recursively bind mounts the host /sys to dest, remounting it
+// read-only if flags includes MS_RDONLY.
+func bindMountSys(dest string, flags uintptr) error {
+	if err := BindMount("/sys", dest, true); err != nil {
+		return err
+	}
+
+	if flags&unix.MS_RDONLY == 0 {
+		return nil
+	}
+
+	return Remount(dest, unix.MS_BIND|unix.MS_RDONLY|(flags&(unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC)))
+}
+
 // getPropagationFlag returns the mount propagation flag for the given opt.
 // Returns 0 if not a propagation option.
 func getPropagationFlag(opt string) uintptr {
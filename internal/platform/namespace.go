@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"syscall"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -38,6 +39,29 @@ var NamespaceEnvs = map[specs.LinuxNamespaceType]string{
 	specs.TimeNamespace:    "time",
 }
 
+// GetNamespaceInodes returns the inode number of each namespace of the process
+// with the given pid, keyed by the name in /proc/<pid>/ns, e.g. net.
+func GetNamespaceInodes(pid int) (map[string]uint64, error) {
+	nsDir := fmt.Sprintf("/proc/%d/ns", pid)
+
+	entries, err := os.ReadDir(nsDir)
+	if err != nil {
+		return nil, fmt.Errorf("read namespaces: %w", err)
+	}
+
+	inodes := make(map[string]uint64, len(entries))
+	for _, entry := range entries {
+		var stat unix.Stat_t
+		if err := unix.Stat(filepath.Join(nsDir, entry.Name()), &stat); err != nil {
+			return nil, fmt.Errorf("stat %s namespace: %w", entry.Name(), err)
+		}
+
+		inodes[entry.Name()] = stat.Ino
+	}
+
+	return inodes, nil
+}
+
 // SetNS enters the namespace specified by the fd.
 func SetNS(fd uintptr) error {
 	_, _, errno := unix.Syscall(unix.SYS_SETNS, uintptr(fd), 0, 0)
@@ -90,45 +114,62 @@ func OpenNSPath(ns *specs.LinuxNamespace) (*os.File, error) {
 // BuildUserNSMappings converts UID/GID mappings from an OCI spec to
 // syscall.SysProcIDMap for user namespace configuration via cmd.Exec.
 // If no mappings are provided then it defaults to mapping the current process'
-// UID/GID.
+// UID/GID to root. When rootless, the user's subordinate IDs are also mapped,
+// from 1.
 func BuildUserNSMappings(
 	specUIDMappings []specs.LinuxIDMapping,
 	specGIDMappings []specs.LinuxIDMapping,
 ) ([]syscall.SysProcIDMap, []syscall.SysProcIDMap) {
-	uidMappings := make([]syscall.SysProcIDMap, 0, max(1, len(specUIDMappings)))
-	gidMappings := make([]syscall.SysProcIDMap, 0, max(1, len(specGIDMappings)))
+	var subUIDs, subGIDs []SubIDRange
 
-	if len(specUIDMappings) > 0 {
-		for _, m := range specUIDMappings {
-			uidMappings = append(uidMappings, syscall.SysProcIDMap{
-				ContainerID: int(m.ContainerID),
-				HostID:      int(m.HostID),
-				Size:        int(m.Size),
-			})
+	if IsRootless() && (len(specUIDMappings) == 0 || len(specGIDMappings) == 0) {
+		var err error
+		if subUIDs, subGIDs, err = GetSubIDRanges(); err != nil {
+			slog.Warn("failed to get subordinate id ranges", "err", err)
 		}
-	} else {
-		uidMappings = append(uidMappings, syscall.SysProcIDMap{
-			ContainerID: 0,
-			HostID:      os.Getuid(),
-			Size:        1,
-		})
 	}
 
-	if len(specGIDMappings) > 0 {
-		for _, m := range specGIDMappings {
-			gidMappings = append(gidMappings, syscall.SysProcIDMap{
+	return buildIDMappings(specUIDMappings, os.Getuid(), subUIDs),
+		buildIDMappings(specGIDMappings, os.Getgid(), subGIDs)
+}
+
+// buildIDMappings converts the given spec mappings, or if there are none maps
+// hostID to root followed by the given subordinate ID ranges.
+func buildIDMappings(
+	specMappings []specs.LinuxIDMapping,
+	hostID int,
+	subIDs []SubIDRange,
+) []syscall.SysProcIDMap {
+	if len(specMappings) > 0 {
+		mappings := make([]syscall.SysProcIDMap, 0, len(specMappings))
+		for _, m := range specMappings {
+			mappings = append(mappings, syscall.SysProcIDMap{
 				ContainerID: int(m.ContainerID),
 				HostID:      int(m.HostID),
 				Size:        int(m.Size),
 			})
 		}
-	} else {
-		gidMappings = append(gidMappings, syscall.SysProcIDMap{
-			ContainerID: 0,
-			HostID:      os.Getgid(),
-			Size:        1,
+
+		return mappings
+	}
+
+	mappings := make([]syscall.SysProcIDMap, 0, 1+len(subIDs))
+	mappings = append(mappings, syscall.SysProcIDMap{
+		ContainerID: 0,
+		HostID:      hostID,
+		Size:        1,
+	})
+
+	containerID := 1
+	for _, r := range subIDs {
+		mappings = append(mappings, syscall.SysProcIDMap{
+			ContainerID: containerID,
+			HostID:      r.Start,
+			Size:        r.Count,
 		})
+
+		containerID += r.Count
 	}
 
-	return uidMappings, gidMappings
+	return mappings
 }
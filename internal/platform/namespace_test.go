@@ -8,6 +8,8 @@ import (
 	"github.com/nixpig/anocir/internal/platform"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 )
 
 func TestNamespaceMappings(t *testing.T) {
@@ -325,3 +327,16 @@ func TestBuildUserNSMappings(t *testing.T) {
 		})
 	}
 }
+
+func TestGetNamespaceInodes(t *testing.T) {
+	t.Parallel()
+
+	inodes, err := platform.GetNamespaceInodes(os.Getpid())
+	require.NoError(t, err)
+
+	var stat unix.Stat_t
+	require.NoError(t, unix.Stat("/proc/self/ns/net", &stat))
+
+	assert.Equal(t, stat.Ino, inodes["net"])
+	assert.Contains(t, inodes, "mnt")
+}
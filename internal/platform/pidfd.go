@@ -0,0 +1,130 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrProcessNotFound is returned when a process doesn't exist, or a process
+// exists with the PID but it's not the expected process, i.e. the PID has been
+// reused.
+var ErrProcessNotFound = errors.New("process not found")
+
+// ProcessStartTime returns the start time of the process with the given pid,
+// in clock ticks since boot, as reported by /proc/<pid>/stat. Together, the
+// PID and start time uniquely identify a process.
+func ProcessStartTime(pid int) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, unix.ESRCH) {
+			return 0, ErrProcessNotFound
+		}
+
+		return 0, fmt.Errorf("read process stat: %w", err)
+	}
+
+	return parseProcessStartTime(string(data))
+}
+
+// parseProcessStartTime parses the start time from the given contents of
+// /proc/<pid>/stat.
+func parseProcessStartTime(stat string) (uint64, error) {
+	_, fields, err := parseProcessStat(stat)
+	if err != nil {
+		return 0, err
+	}
+
+	startTime, err := strconv.ParseUint(statField(fields, 22), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse process start time: %w", err)
+	}
+
+	return startTime, nil
+}
+
+// OpenPidfd opens a pidfd for the process with the given pid and verifies the
+// process has the given startTime. A zero startTime skips verification. If
+// the process doesn't exist or doesn't match, ErrProcessNotFound is returned.
+// The caller is responsible for closing the returned pidfd.
+func OpenPidfd(pid int, startTime uint64) (int, error) {
+	pidfd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return -1, ErrProcessNotFound
+		}
+
+		return -1, fmt.Errorf("pidfd open: %w", err)
+	}
+
+	// The pidfd refers to whichever process has the PID at the time it was
+	// opened, so verify that process is the expected one.
+	if err := verifyProcess(pid, startTime); err != nil {
+		unix.Close(pidfd)
+		return -1, err
+	}
+
+	return pidfd, nil
+}
+
+// SignalProcess sends sig to the process with the given pid, provided it has
+// the given startTime. A pidfd is used where available, so the signal can't
+// be delivered to a different process that reused the PID. A zero startTime
+// skips verification.
+func SignalProcess(pid int, startTime uint64, sig unix.Signal) error {
+	pidfd, err := OpenPidfd(pid, startTime)
+	if err != nil {
+		if !errors.Is(err, unix.ENOSYS) {
+			return err
+		}
+
+		// Kernel doesn't support pidfds, so fall back to verifying the start
+		// time and using the PID directly.
+		if err := verifyProcess(pid, startTime); err != nil {
+			return err
+		}
+
+		return SendSignal(pid, sig)
+	}
+	defer unix.Close(pidfd)
+
+	if err := unix.PidfdSendSignal(pidfd, sig, nil, 0); err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return ErrProcessNotFound
+		}
+
+		return fmt.Errorf("pidfd send signal: %w", err)
+	}
+
+	return nil
+}
+
+// IsProcessAlive reports whether the process with the given pid exists and
+// has the given startTime. A zero startTime skips verification.
+func IsProcessAlive(pid int, startTime uint64) (bool, error) {
+	if err := verifyProcess(pid, startTime); err != nil {
+		if errors.Is(err, ErrProcessNotFound) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func verifyProcess(pid int, startTime uint64) error {
+	actual, err := ProcessStartTime(pid)
+	if err != nil {
+		return err
+	}
+
+	if startTime != 0 && actual != startTime {
+		return ErrProcessNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package platform
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseProcessStartTime(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		stat      string
+		startTime uint64
+		wantErr   bool
+	}{
+		"test simple comm": {
+			stat:      "1234 (sleep) S 1 1234 1234 0 -1 4194304 90 0 0 0 0 0 0 0 20 0 1 0 987654 2232320 128 18446744073709551615",
+			startTime: 987654,
+		},
+		"test comm with spaces and parentheses": {
+			stat:      "1234 (a b) (c) S 1 1234 1234 0 -1 4194304 90 0 0 0 0 0 0 0 20 0 1 0 42 2232320 128 18446744073709551615",
+			startTime: 42,
+		},
+		"test missing comm": {
+			stat:    "1234 sleep S 1",
+			wantErr: true,
+		},
+		"test truncated stat": {
+			stat:    "1234 (sleep) S 1 1234",
+			wantErr: true,
+		},
+	}
+
+	for name, data := range scenarios {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			startTime, err := parseProcessStartTime(data.stat)
+			if data.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, data.startTime, startTime)
+		})
+	}
+}
+
+func TestSignalProcess(t *testing.T) {
+	t.Parallel()
+
+	pid := os.Getpid()
+
+	startTime, err := ProcessStartTime(pid)
+	require.NoError(t, err)
+	require.NotZero(t, startTime)
+
+	assert.NoError(t, SignalProcess(pid, startTime, unix.Signal(0)))
+	assert.ErrorIs(t, SignalProcess(pid, startTime+1, unix.Signal(0)), ErrProcessNotFound)
+
+	alive, err := IsProcessAlive(pid, startTime)
+	assert.NoError(t, err)
+	assert.True(t, alive)
+
+	alive, err = IsProcessAlive(pid, startTime+1)
+	assert.NoError(t, err)
+	assert.False(t, alive)
+
+	alive, err = IsProcessAlive(1<<30, 0)
+	assert.NoError(t, err)
+	assert.False(t, alive)
+}
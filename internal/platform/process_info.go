@@ -0,0 +1,180 @@
+package platform
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// clockTicks is the number of clock ticks per second used for times in
+	// /proc/<pid>/stat, i.e. USER_HZ, which is 100 on all Linux platforms.
+	clockTicks = 100
+
+	// statFieldOffset is the field number in /proc/<pid>/stat of the first
+	// field after comm, i.e. state.
+	statFieldOffset = 3
+)
+
+// ProcessInfo holds details about a process read from /proc.
+type ProcessInfo struct {
+	// PID is the PID of the process in the host PID namespace.
+	PID int `json:"pid"`
+	// NSPID is the PID of the process in its own PID namespace.
+	NSPID int `json:"nspid"`
+	// PPID is the PID of the parent process in the host PID namespace.
+	PPID int    `json:"ppid"`
+	UID  int    `json:"uid"`
+	User string `json:"user"`
+	// State is the process state, e.g. R (running) or S (sleeping).
+	State     string        `json:"state"`
+	StartTime time.Time     `json:"startTime"`
+	CPUTime   time.Duration `json:"cpuTime"`
+	// RSS is the resident set size of the process, in bytes.
+	RSS     int64  `json:"rss"`
+	Command string `json:"command"`
+}
+
+// GetProcessInfo reads the ProcessInfo of the process with the given pid from
+// /proc.
+func GetProcessInfo(pid int) (*ProcessInfo, error) {
+	procDir := fmt.Sprintf("/proc/%d", pid)
+
+	stat, err := os.ReadFile(procDir + "/stat")
+	if err != nil {
+		return nil, fmt.Errorf("read process stat: %w", err)
+	}
+
+	comm, fields, err := parseProcessStat(string(stat))
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ProcessInfo{PID: pid, NSPID: pid, State: statField(fields, 3)}
+
+	info.PPID, _ = strconv.Atoi(statField(fields, 4))
+
+	utime, _ := strconv.ParseUint(statField(fields, 14), 10, 64)
+	stime, _ := strconv.ParseUint(statField(fields, 15), 10, 64)
+	info.CPUTime = ticksToDuration(utime + stime)
+
+	startTicks, _ := strconv.ParseUint(statField(fields, 22), 10, 64)
+	if bootTime, err := getBootTime(); err == nil {
+		info.StartTime = bootTime.Add(ticksToDuration(startTicks))
+	}
+
+	rssPages, _ := strconv.ParseInt(statField(fields, 24), 10, 64)
+	info.RSS = rssPages * int64(os.Getpagesize())
+
+	status, err := os.ReadFile(procDir + "/status")
+	if err != nil {
+		return nil, fmt.Errorf("read process status: %w", err)
+	}
+
+	parseProcessStatus(string(status), info)
+
+	info.User = strconv.Itoa(info.UID)
+	if u, err := user.LookupId(info.User); err == nil {
+		info.User = u.Username
+	}
+
+	cmdline, err := os.ReadFile(procDir + "/cmdline")
+	if err != nil {
+		return nil, fmt.Errorf("read process cmdline: %w", err)
+	}
+
+	info.Command = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	if info.Command == "" {
+		// Kernel threads and zombies don't have a cmdline.
+		info.Command = "[" + comm + "]"
+	}
+
+	return info, nil
+}
+
+// parseProcessStat parses the given contents of /proc/<pid>/stat into the comm
+// field and the fields that follow it.
+func parseProcessStat(stat string) (string, []string, error) {
+	// The comm field can contain spaces and parentheses, so parse fields from
+	// after the last closing parenthesis.
+	start := strings.IndexByte(stat, '(')
+	end := strings.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return "", nil, errors.New("invalid process stat format")
+	}
+
+	return stat[start+1 : end], strings.Fields(stat[end+1:]), nil
+}
+
+// statField returns the field with the given number, as documented in
+// proc_pid_stat(5), from the fields following comm.
+func statField(fields []string, n int) string {
+	i := n - statFieldOffset
+	if i < 0 || i >= len(fields) {
+		return ""
+	}
+
+	return fields[i]
+}
+
+// parseProcessStatus parses the UID and namespace PID from the given contents
+// of /proc/<pid>/status into info.
+func parseProcessStatus(status string, info *ProcessInfo) {
+	scanner := bufio.NewScanner(strings.NewReader(status))
+
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch key {
+		case "Uid":
+			info.UID, _ = strconv.Atoi(fields[0])
+		case "NSpid":
+			// NSpid lists the PID in each nested PID namespace, from the
+			// outermost to the innermost.
+			info.NSPID, _ = strconv.Atoi(fields[len(fields)-1])
+		}
+	}
+}
+
+// getBootTime returns the system boot time from /proc/stat.
+func getBootTime() (time.Time, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("open stat: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "btime ")
+		if !ok {
+			continue
+		}
+
+		btime, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse boot time: %w", err)
+		}
+
+		return time.Unix(btime, 0), nil
+	}
+
+	return time.Time{}, errors.New("boot time not found")
+}
+
+func ticksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * time.Second / clockTicks
+}
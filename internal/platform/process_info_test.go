@@ -0,0 +1,58 @@
+package platform
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetProcessInfo(t *testing.T) {
+	t.Parallel()
+
+	info, err := GetProcessInfo(os.Getpid())
+	require.NoError(t, err)
+
+	assert.Equal(t, os.Getpid(), info.PID)
+	assert.Equal(t, os.Getppid(), info.PPID)
+	assert.Equal(t, os.Getuid(), info.UID)
+	assert.NotZero(t, info.NSPID)
+	assert.NotEmpty(t, info.State)
+	assert.NotZero(t, info.StartTime)
+	assert.Positive(t, info.RSS)
+	assert.Contains(t, info.Command, os.Args[0])
+}
+
+func TestParseProcessStatus(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		status string
+		uid    int
+		nspid  int
+	}{
+		"test nested pid namespace": {
+			status: "Name:\tsh\nUid:\t1000\t1000\t1000\t1000\nNSpid:\t4321\t1\n",
+			uid:    1000,
+			nspid:  1,
+		},
+		"test host pid namespace": {
+			status: "Name:\tsh\nUid:\t0\t0\t0\t0\nNSpid:\t4321\n",
+			uid:    0,
+			nspid:  4321,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			info := &ProcessInfo{}
+			parseProcessStatus(data.status, info)
+
+			assert.Equal(t, data.uid, info.UID)
+			assert.Equal(t, data.nspid, info.NSPID)
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package platform
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"slices"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -14,6 +16,10 @@ type ProcessSecurity struct {
 	NoNewPrivs      bool
 	AppArmorProfile string
 	ProcessLabel    string
+	// SeccompListener is called with the seccomp notify fd as soon as the
+	// seccomp filter is loaded, if it uses SCMP_ACT_NOTIFY. It's expected to
+	// hand the fd to the seccomp agent before returning.
+	SeccompListener func(*os.File) error
 }
 
 func ApplyProcessSecurity(opts *ProcessSecurity) error {
@@ -21,7 +27,7 @@ func ApplyProcessSecurity(opts *ProcessSecurity) error {
 	// capabilities because seccomp filter loading is a privileged operation that
 	// requires CAP_SYS_ADMIN when NO_NEW_PRIVS is not set.
 	if opts.Seccomp != nil && !opts.NoNewPrivs {
-		if err := LoadSeccompFilter(opts.Seccomp); err != nil {
+		if err := loadSeccompFilter(opts); err != nil {
 			return fmt.Errorf("load seccomp filter (privileged): %w", err)
 		}
 	}
@@ -74,7 +80,7 @@ func ApplyProcessSecurity(opts *ProcessSecurity) error {
 	// as close to execve as possible to minimize the syscall surface. The
 	// NO_NEW_PRIVS bit allows unprivileged seccomp filter loading.
 	if opts.Seccomp != nil && opts.NoNewPrivs {
-		if err := LoadSeccompFilter(opts.Seccomp); err != nil {
+		if err := loadSeccompFilter(opts); err != nil {
 			return fmt.Errorf("load seccomp filter: %w", err)
 		}
 	}
@@ -93,3 +99,27 @@ func ApplyProcessSecurity(opts *ProcessSecurity) error {
 
 	return nil
 }
+
+// loadSeccompFilter loads the seccomp filter of opts and passes any notify fd
+// to its SeccompListener.
+func loadSeccompFilter(opts *ProcessSecurity) error {
+	notifyFD, err := LoadSeccompFilter(opts.Seccomp)
+	if err != nil {
+		return err
+	}
+
+	if notifyFD == nil {
+		return nil
+	}
+	defer notifyFD.Close()
+
+	if opts.SeccompListener == nil {
+		return errors.New("no seccomp listener for notify fd")
+	}
+
+	if err := opts.SeccompListener(notifyFD); err != nil {
+		return fmt.Errorf("send seccomp notify fd: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// runtimeDirName is the name of the runtime directory, under /run or, for
+	// rootless containers, $XDG_RUNTIME_DIR.
+	runtimeDirName = "anocir"
+
+	subUIDFile = "/etc/subuid"
+	subGIDFile = "/etc/subgid"
+)
+
+// SubIDRange is a range of subordinate IDs allocated to a user in /etc/subuid
+// or /etc/subgid.
+type SubIDRange struct {
+	Start int
+	Count int
+}
+
+// IsRootless reports whether the runtime is running without root, i.e. with a
+// non-zero effective UID.
+func IsRootless() bool {
+	return os.Geteuid() != 0
+}
+
+// RuntimeDir returns the directory the runtime keeps container state and
+// sockets in. When rootless this is $XDG_RUNTIME_DIR/anocir, falling back to
+// a per-user directory in the temp dir if it isn't set.
+func RuntimeDir() string {
+	if !IsRootless() {
+		return filepath.Join("/run", runtimeDirName)
+	}
+
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, runtimeDirName)
+	}
+
+	return filepath.Join(
+		os.TempDir(),
+		fmt.Sprintf("%s-%d", runtimeDirName, os.Geteuid()),
+	)
+}
+
+// GetSubIDRanges returns the subordinate UID and GID ranges allocated to the
+// current user in /etc/subuid and /etc/subgid.
+func GetSubIDRanges() ([]SubIDRange, []SubIDRange, error) {
+	u, err := user.LookupId(strconv.Itoa(os.Getuid()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("lookup current user: %w", err)
+	}
+
+	subUIDs, err := readSubIDRanges(subUIDFile, u.Username, u.Uid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subGIDs, err := readSubIDRanges(subGIDFile, u.Username, u.Uid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return subUIDs, subGIDs, nil
+}
+
+func readSubIDRanges(path, name, id string) ([]SubIDRange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	ranges, err := parseSubIDRanges(f, name, id)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	return ranges, nil
+}
+
+// parseSubIDRanges parses the ranges for the user with the given name or id
+// from the given contents of /etc/subuid or /etc/subgid, where each line is
+// 'name-or-id:start:count'.
+func parseSubIDRanges(r io.Reader, name, id string) ([]SubIDRange, error) {
+	var ranges []SubIDRange
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid line: %s", line)
+		}
+
+		if parts[0] != name && parts[0] != id {
+			continue
+		}
+
+		start, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("parse start '%s': %w", parts[1], err)
+		}
+
+		count, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("parse count '%s': %w", parts[2], err)
+		}
+
+		ranges = append(ranges, SubIDRange{Start: start, Count: count})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read ranges: %w", err)
+	}
+
+	return ranges, nil
+}
+
+// NeedsIDMapHelpers reports whether the given mappings can only be written
+// with newuidmap and newgidmap, i.e. the runtime is rootless and the mappings
+// are more than a single mapping of its own UID and GID.
+func NeedsIDMapHelpers(
+	uidMappings []syscall.SysProcIDMap,
+	gidMappings []syscall.SysProcIDMap,
+) bool {
+	if !IsRootless() {
+		return false
+	}
+
+	isOwnID := func(mappings []syscall.SysProcIDMap, id int) bool {
+		return len(mappings) == 1 &&
+			mappings[0].HostID == id &&
+			mappings[0].Size == 1
+	}
+
+	return !isOwnID(uidMappings, os.Getuid()) ||
+		!isOwnID(gidMappings, os.Getgid())
+}
+
+// WriteIDMappings writes the given mappings for the user namespace of the
+// process with the given pid, using newuidmap and newgidmap.
+func WriteIDMappings(
+	pid int,
+	uidMappings []syscall.SysProcIDMap,
+	gidMappings []syscall.SysProcIDMap,
+) error {
+	if err := runIDMapHelper("newuidmap", pid, uidMappings); err != nil {
+		return err
+	}
+
+	return runIDMapHelper("newgidmap", pid, gidMappings)
+}
+
+func runIDMapHelper(helper string, pid int, mappings []syscall.SysProcIDMap) error {
+	args := []string{strconv.Itoa(pid)}
+	for _, m := range mappings {
+		args = append(
+			args,
+			strconv.Itoa(m.ContainerID),
+			strconv.Itoa(m.HostID),
+			strconv.Itoa(m.Size),
+		)
+	}
+
+	if out, err := exec.Command(helper, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf(
+			"%s %s: %w: %s",
+			helper, strings.Join(args, " "), err, strings.TrimSpace(string(out)),
+		)
+	}
+
+	return nil
+}
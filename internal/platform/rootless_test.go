@@ -0,0 +1,116 @@
+package platform
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubIDRanges(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		contents string
+		ranges   []SubIDRange
+		wantErr  bool
+	}{
+		"test match by name": {
+			contents: "alice:100000:65536\nbob:165536:65536\n",
+			ranges:   []SubIDRange{{Start: 100000, Count: 65536}},
+		},
+		"test match by id": {
+			contents: "1000:100000:65536\n",
+			ranges:   []SubIDRange{{Start: 100000, Count: 65536}},
+		},
+		"test multiple ranges with comments": {
+			contents: "# ranges\nalice:100000:65536\n\nalice:300000:1000\n",
+			ranges: []SubIDRange{
+				{Start: 100000, Count: 65536},
+				{Start: 300000, Count: 1000},
+			},
+		},
+		"test no ranges": {
+			contents: "bob:165536:65536\n",
+			ranges:   nil,
+		},
+		"test invalid line": {
+			contents: "alice:100000\n",
+			wantErr:  true,
+		},
+		"test invalid count": {
+			contents: "alice:100000:lots\n",
+			wantErr:  true,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			ranges, err := parseSubIDRanges(
+				strings.NewReader(data.contents),
+				"alice",
+				"1000",
+			)
+			if data.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, data.ranges, ranges)
+		})
+	}
+}
+
+func TestBuildIDMappings(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		specMappings []specs.LinuxIDMapping
+		subIDs       []SubIDRange
+		mappings     []syscall.SysProcIDMap
+	}{
+		"test own id only": {
+			mappings: []syscall.SysProcIDMap{
+				{ContainerID: 0, HostID: 1000, Size: 1},
+			},
+		},
+		"test own id and subordinate ids": {
+			subIDs: []SubIDRange{
+				{Start: 100000, Count: 65536},
+				{Start: 300000, Count: 1000},
+			},
+			mappings: []syscall.SysProcIDMap{
+				{ContainerID: 0, HostID: 1000, Size: 1},
+				{ContainerID: 1, HostID: 100000, Size: 65536},
+				{ContainerID: 65537, HostID: 300000, Size: 1000},
+			},
+		},
+		"test spec mappings ignore subordinate ids": {
+			specMappings: []specs.LinuxIDMapping{
+				{ContainerID: 0, HostID: 200000, Size: 1000},
+			},
+			subIDs: []SubIDRange{{Start: 100000, Count: 65536}},
+			mappings: []syscall.SysProcIDMap{
+				{ContainerID: 0, HostID: 200000, Size: 1000},
+			},
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(
+				t,
+				data.mappings,
+				buildIDMappings(data.specMappings, 1000, data.subIDs),
+			)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"slices"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -202,18 +203,34 @@ func buildSeccompFilter(spec *specs.LinuxSeccomp) (*libseccomp.ScmpFilter, error
 	return filter, nil
 }
 
-func LoadSeccompFilter(spec *specs.LinuxSeccomp) error {
+// LoadSeccompFilter loads the seccomp filter for the given spec into the
+// current process. If the filter uses SCMP_ACT_NOTIFY, it returns the notify
+// fd, which the caller must close.
+func LoadSeccompFilter(spec *specs.LinuxSeccomp) (*os.File, error) {
 	filter, err := buildSeccompFilter(spec)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer filter.Release()
 
 	if err := filter.SetNoNewPrivsBit(false); err != nil {
-		return fmt.Errorf("set seccomp no new privs bit: %w", err)
+		return nil, fmt.Errorf("set seccomp no new privs bit: %w", err)
 	}
 
-	return filter.Load()
+	if err := filter.Load(); err != nil {
+		return nil, err
+	}
+
+	if !UsesSeccompNotify(spec) {
+		return nil, nil
+	}
+
+	fd, err := filter.GetNotifFd()
+	if err != nil {
+		return nil, fmt.Errorf("get seccomp notify fd: %w", err)
+	}
+
+	return os.NewFile(uintptr(fd), "seccomp_notify"), nil
 }
 
 func buildSeccompAction(sc specs.LinuxSyscall, defaultErrnoRet *uint) libseccomp.ScmpAction {
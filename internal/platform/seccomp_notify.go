@@ -0,0 +1,98 @@
+package platform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// seccompListenerSyscalls is the syscalls a container process makes to send
+// its seccomp notify fd to the runtime, after its seccomp filter is loaded.
+// They can't use SCMP_ACT_NOTIFY, since there'd be no agent to respond yet.
+var seccompListenerSyscalls = []string{"sendmsg"}
+
+// UsesSeccompNotify returns whether the given seccomp profile has any rules
+// with the SCMP_ACT_NOTIFY action.
+func UsesSeccompNotify(spec *specs.LinuxSeccomp) bool {
+	if spec == nil {
+		return false
+	}
+
+	return slices.ContainsFunc(spec.Syscalls, func(sc specs.LinuxSyscall) bool {
+		return sc.Action == specs.ActNotify
+	})
+}
+
+// ValidateSeccompNotify checks SCMP_ACT_NOTIFY is used in the given seccomp
+// profile as the runtime spec allows, i.e. not as the default action and only
+// with a listenerPath.
+func ValidateSeccompNotify(spec *specs.LinuxSeccomp) error {
+	if spec == nil {
+		return nil
+	}
+
+	if spec.DefaultAction == specs.ActNotify {
+		return errors.New("SCMP_ACT_NOTIFY cannot be the default action")
+	}
+
+	if !UsesSeccompNotify(spec) {
+		return nil
+	}
+
+	if spec.ListenerPath == "" {
+		return errors.New("SCMP_ACT_NOTIFY requires a listenerPath")
+	}
+
+	for _, sc := range spec.Syscalls {
+		if sc.Action != specs.ActNotify {
+			continue
+		}
+
+		for _, name := range seccompListenerSyscalls {
+			if slices.Contains(sc.Names, name) {
+				return fmt.Errorf("SCMP_ACT_NOTIFY cannot be used for %s", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SendSeccompListener sends the given seccomp notify fd, with the given state,
+// to the seccomp agent listening on the unix socket at listenerPath.
+func SendSeccompListener(
+	listenerPath string,
+	fd *os.File,
+	state *specs.ContainerProcessState,
+) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal container process state: %w", err)
+	}
+
+	conn, err := net.DialUnix(
+		"unix",
+		nil,
+		&net.UnixAddr{Name: listenerPath, Net: "unix"},
+	)
+	if err != nil {
+		return fmt.Errorf("connect to seccomp agent: %w", err)
+	}
+	defer conn.Close()
+
+	if _, _, err := conn.WriteMsgUnix(
+		data,
+		unix.UnixRights(int(fd.Fd())),
+		nil,
+	); err != nil {
+		return fmt.Errorf("send seccomp notify fd: %w", err)
+	}
+
+	return nil
+}
@@ -1,11 +1,17 @@
 package platform
 
 import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
 	libseccomp "github.com/seccomp/libseccomp-golang"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
 )
 
 func TestMapSeccompAction(t *testing.T) {
@@ -37,3 +43,128 @@ func TestMapSeccompArch(t *testing.T) {
 		assert.Equal(t, libseccomp.ArchInvalid, mapSeccompArch(specs.Arch("SOMETHING INVALID")))
 	})
 }
+
+func TestValidateSeccompNotify(t *testing.T) {
+	t.Parallel()
+
+	scenarios := map[string]struct {
+		seccomp *specs.LinuxSeccomp
+		wantErr bool
+	}{
+		"test no seccomp": {
+			seccomp: nil,
+		},
+		"test no notify rules": {
+			seccomp: &specs.LinuxSeccomp{
+				DefaultAction: specs.ActAllow,
+				Syscalls: []specs.LinuxSyscall{
+					{Names: []string{"mount"}, Action: specs.ActErrno},
+				},
+			},
+		},
+		"test notify rules with listener path": {
+			seccomp: &specs.LinuxSeccomp{
+				DefaultAction: specs.ActAllow,
+				ListenerPath:  "/run/agent.sock",
+				Syscalls: []specs.LinuxSyscall{
+					{Names: []string{"mount"}, Action: specs.ActNotify},
+				},
+			},
+		},
+		"test notify rules without listener path": {
+			seccomp: &specs.LinuxSeccomp{
+				DefaultAction: specs.ActAllow,
+				Syscalls: []specs.LinuxSyscall{
+					{Names: []string{"mount"}, Action: specs.ActNotify},
+				},
+			},
+			wantErr: true,
+		},
+		"test notify default action": {
+			seccomp: &specs.LinuxSeccomp{
+				DefaultAction: specs.ActNotify,
+				ListenerPath:  "/run/agent.sock",
+			},
+			wantErr: true,
+		},
+		"test notify sendmsg": {
+			seccomp: &specs.LinuxSeccomp{
+				DefaultAction: specs.ActAllow,
+				ListenerPath:  "/run/agent.sock",
+				Syscalls: []specs.LinuxSyscall{
+					{Names: []string{"mount", "sendmsg"}, Action: specs.ActNotify},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateSeccompNotify(data.seccomp)
+			if data.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSendSeccompListener(t *testing.T) {
+	t.Parallel()
+
+	listenerPath := filepath.Join(t.TempDir(), "agent.sock")
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: listenerPath, Net: "unix"})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	f, err := os.CreateTemp(t.TempDir(), "notify")
+	require.NoError(t, err)
+	defer f.Close()
+
+	state := &specs.ContainerProcessState{
+		Version:  specs.Version,
+		Fds:      []string{specs.SeccompFdName},
+		Pid:      1234,
+		Metadata: "metadata",
+		State:    specs.State{ID: "test-container", Status: specs.StateRunning},
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- SendSeccompListener(listenerPath, f, state)
+	}()
+
+	conn, err := listener.AcceptUnix()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	buf := make([]byte, 4096)
+	oob := make([]byte, unix.CmsgSpace(4))
+
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	require.NoError(t, err)
+	require.NoError(t, <-errCh)
+
+	var received specs.ContainerProcessState
+	require.NoError(t, json.Unmarshal(buf[:n], &received))
+	assert.Equal(t, *state, received)
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	require.NoError(t, err)
+	require.Len(t, fds, 1)
+	defer unix.Close(fds[0])
+
+	var want, got unix.Stat_t
+	require.NoError(t, unix.Fstat(int(f.Fd()), &want))
+	require.NoError(t, unix.Fstat(fds[0], &got))
+	assert.Equal(t, want.Ino, got.Ino)
+}
@@ -0,0 +1,55 @@
+package platform
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// soAcceptCon is the flag set in /proc/net/unix for listening sockets.
+const soAcceptCon = 1 << 16
+
+// IsUnixSocketListening reports whether a unix socket bound to the given path
+// in the current network namespace is listening for connections.
+func IsUnixSocketListening(path string) (bool, error) {
+	f, err := os.Open("/proc/net/unix")
+	if err != nil {
+		return false, fmt.Errorf("open unix sockets: %w", err)
+	}
+	defer f.Close()
+
+	return isUnixSocketListening(f, path)
+}
+
+func isUnixSocketListening(r io.Reader, path string) (bool, error) {
+	scanner := bufio.NewScanner(r)
+
+	// Skip the header.
+	scanner.Scan()
+
+	for scanner.Scan() {
+		// Num RefCount Protocol Flags Type St Inode Path
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[7] != path {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			return false, fmt.Errorf("parse unix socket flags: %w", err)
+		}
+
+		if flags&soAcceptCon != 0 {
+			return true, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("read unix sockets: %w", err)
+	}
+
+	return false, nil
+}
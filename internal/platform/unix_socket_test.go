@@ -0,0 +1,46 @@
+package platform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsUnixSocketListening(t *testing.T) {
+	t.Parallel()
+
+	sockets := `Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 1001 /run/anocir/0123456789abcdef/c.sock
+0000000000000000: 00000003 00000000 00000000 0001 03 1002 /run/anocir/fedcba9876543210/c.sock
+0000000000000000: 00000003 00000000 00000000 0001 03 1003
+`
+
+	scenarios := map[string]struct {
+		path      string
+		listening bool
+	}{
+		"test listening socket": {
+			path:      "/run/anocir/0123456789abcdef/c.sock",
+			listening: true,
+		},
+		"test connected socket": {
+			path:      "/run/anocir/fedcba9876543210/c.sock",
+			listening: false,
+		},
+		"test unknown socket": {
+			path:      "/run/anocir/missing/c.sock",
+			listening: false,
+		},
+	}
+
+	for scenario, data := range scenarios {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			listening, err := isUnixSocketListening(strings.NewReader(sockets), data.path)
+			assert.NoError(t, err)
+			assert.Equal(t, data.listening, listening)
+		})
+	}
+}
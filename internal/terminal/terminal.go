@@ -4,7 +4,9 @@ package terminal
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"unsafe"
 
@@ -156,3 +158,83 @@ func SendPty(consoleSocket int, pty *Pty) error {
 
 	return nil
 }
+
+// ReceivePty receives a Pty Master file descriptor sent with SendPty over the
+// given unix domain socket conn.
+func ReceivePty(conn *net.UnixConn) (*os.File, error) {
+	buf := make([]byte, 8)
+	oob := make([]byte, unix.CmsgSpace(4))
+
+	_, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, fmt.Errorf("terminal recvmsg: %w", err)
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, fmt.Errorf("parse socket control message: %w", err)
+	}
+
+	if len(msgs) == 0 {
+		return nil, errors.New("no socket control message received")
+	}
+
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse unix rights: %w", err)
+	}
+
+	if len(fds) != 1 {
+		return nil, fmt.Errorf("expected 1 file descriptor but got %d", len(fds))
+	}
+
+	return os.NewFile(uintptr(fds[0]), "pty_master"), nil
+}
+
+// IsTerminal reports whether the given fd refers to a terminal.
+func IsTerminal(fd int) bool {
+	_, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	return err == nil
+}
+
+// SetRawMode puts the terminal referred to by fd into raw mode and returns a
+// function that restores its previous state.
+func SetRawMode(fd int) (func() error, error) {
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, fmt.Errorf("get termios: %w", err)
+	}
+
+	raw := *termios
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP |
+		unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &raw); err != nil {
+		return nil, fmt.Errorf("set raw termios: %w", err)
+	}
+
+	return func() error {
+		return unix.IoctlSetTermios(fd, unix.TCSETS, termios)
+	}, nil
+}
+
+// CopyWinSize copies the window size of the terminal referred to by src to
+// the terminal referred to by dst.
+func CopyWinSize(dst, src int) error {
+	ws, err := unix.IoctlGetWinsize(src, unix.TIOCGWINSZ)
+	if err != nil {
+		return fmt.Errorf("get winsize: %w", err)
+	}
+
+	if err := unix.IoctlSetWinsize(dst, unix.TIOCSWINSZ, ws); err != nil {
+		return fmt.Errorf("set winsize: %w", err)
+	}
+
+	return nil
+}
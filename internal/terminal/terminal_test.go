@@ -3,6 +3,7 @@ package terminal_test
 import (
 	"errors"
 	"net"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -166,3 +167,104 @@ func TestSendPty_InvalidSocket(t *testing.T) {
 
 	assert.Error(t, terminal.SendPty(-1, pty))
 }
+
+func TestReceivePty(t *testing.T) {
+	pty, err := terminal.NewPty()
+	require.NoError(t, err)
+
+	defer func() {
+		pty.Master.Close()
+		pty.Slave.Close()
+	}()
+
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	ptySocket, err := terminal.NewPtySocket(socketPath)
+	require.NoError(t, err)
+	defer ptySocket.Close()
+
+	conn, err := listener.AcceptUnix()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, terminal.SendPty(ptySocket.SocketFd, pty))
+
+	master, err := terminal.ReceivePty(conn)
+	require.NoError(t, err)
+	defer master.Close()
+
+	_, err = pty.Slave.WriteString("test")
+	require.NoError(t, err)
+
+	buf := make([]byte, 64)
+	n, err := master.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "test", string(buf[:n]))
+}
+
+func TestSetRawMode(t *testing.T) {
+	pty, err := terminal.NewPty()
+	require.NoError(t, err)
+
+	defer func() {
+		pty.Master.Close()
+		pty.Slave.Close()
+	}()
+
+	fd := int(pty.Slave.Fd())
+
+	assert.True(t, terminal.IsTerminal(fd))
+
+	restore, err := terminal.SetRawMode(fd)
+	require.NoError(t, err)
+
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	require.NoError(t, err)
+	assert.Zero(t, termios.Lflag&(unix.ECHO|unix.ICANON))
+
+	require.NoError(t, restore())
+
+	termios, err = unix.IoctlGetTermios(fd, unix.TCGETS)
+	require.NoError(t, err)
+	assert.NotZero(t, termios.Lflag&unix.ICANON)
+}
+
+func TestCopyWinSize(t *testing.T) {
+	src, err := terminal.NewPty()
+	require.NoError(t, err)
+
+	dst, err := terminal.NewPty()
+	require.NoError(t, err)
+
+	defer func() {
+		src.Master.Close()
+		src.Slave.Close()
+		dst.Master.Close()
+		dst.Slave.Close()
+	}()
+
+	require.NoError(t, unix.IoctlSetWinsize(
+		int(src.Slave.Fd()),
+		unix.TIOCSWINSZ,
+		&unix.Winsize{Row: 24, Col: 80},
+	))
+
+	require.NoError(t, terminal.CopyWinSize(int(dst.Master.Fd()), int(src.Slave.Fd())))
+
+	ws, err := unix.IoctlGetWinsize(int(dst.Slave.Fd()), unix.TIOCGWINSZ)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(24), ws.Row)
+	assert.Equal(t, uint16(80), ws.Col)
+}
+
+func TestIsTerminal_NotTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "file")
+	require.NoError(t, err)
+	defer f.Close()
+
+	assert.False(t, terminal.IsTerminal(int(f.Fd())))
+}